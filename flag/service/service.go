@@ -1,8 +1,68 @@
 package service
 
 type Service struct {
-	CheckAPI      string
-	FlannelFile   string
-	ListenAddress string
-	IPAddress     string
+	APICAFile              string
+	APICertExpiryWarning   string
+	APIClientCertFile      string
+	APIClientKeyFile       string
+	APIExpectedBody        string
+	APIExpectedStatus      string
+	APIHost                string
+	APILatencyWarning      string
+	APIPath                string
+	APIPort                string
+	APIScheme              string
+	APIServerName          string
+	APITokenFile           string
+	APIVerbose             string
+	AddressFile            string
+	AddressFixedIPs        string
+	AddressOffset          string
+	AddressStrategy        string
+	CheckAPI               string
+	CheckDependencies      string
+	CheckInterval          string
+	CheckJitter            string
+	CheckSeverities        string
+	Checks                 string
+	ChecksFile             string
+	FailureThreshold       string
+	FlannelFile            string
+	FlannelWaitInterval    string
+	FlannelWaitMaxInterval string
+	FlannelWaitTimeout     string
+	HealthzStatusCodes     string
+	HistorySize            string
+	IPAddress              string
+	IPFamily               string
+	IPv6Address            string
+	KubeletExpectedBody    string
+	KubeletExpectedStatus  string
+	KubeletHost            string
+	KubeletPath            string
+	KubeletPort            string
+	KubeletScheme          string
+	ListenAddress          string
+	LivezChecks            string
+	LivezStatusCodes       string
+	PingCount              string
+	PingInterval           string
+	PingMaxAvgRTT          string
+	PingMaxLoss            string
+	PingMaxP99RTT          string
+	PingMode               string
+	PingSize               string
+	PingTCPPort            string
+	PingTimeout            string
+	ProbeBudget            string
+	ReadyzChecks           string
+	ReadyzStatusCodes      string
+	StartupGracePeriod     string
+	StartupzChecks         string
+	StartupzStatusCodes    string
+	StateFile              string
+	StateMaxAge            string
+	StateSaveInterval      string
+	SuccessThreshold       string
+	WatchPollInterval      string
 }
@@ -3,6 +3,7 @@ module github.com/giantswarm/k8s-kvm-health
 go 1.14
 
 require (
+	github.com/fsnotify/fsnotify v1.4.9
 	github.com/giantswarm/microendpoint v0.0.0-20180904075734-f77c569259ae
 	github.com/giantswarm/microerror v0.0.0-20181001144842-3bc3cb1a3670
 	github.com/giantswarm/microkit v0.0.0-20181107110722-aaff79223ca0
@@ -11,10 +12,13 @@ require (
 	github.com/go-kit/kit v0.11.0
 	github.com/go-resty/resty v0.0.0-00010101000000-000000000000 // indirect
 	github.com/juju/errgo v0.0.0-20140925100237-08cceb5d0b53 // indirect
+	github.com/prometheus/client_golang v1.11.0
 	github.com/sparrc/go-ping v0.0.0-20181106165434-ef3ab45e41b0
 	github.com/spf13/cobra v1.2.1 // indirect
 	github.com/spf13/viper v1.8.1
+	golang.org/x/net v0.0.0-20210405180319-a5a99cb37ef4
 	gopkg.in/resty.v1 v1.12.0 // indirect
+	gopkg.in/yaml.v2 v2.4.0
 )
 
 replace (
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"syscall"
 
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/microkit/command"
@@ -32,9 +33,68 @@ func main() {
 
 func readEnv() error {
 	// load conf from ENV
+	f.Service.APICAFile = os.Getenv("K8S_API_CA_FILE")
+	f.Service.APICertExpiryWarning = os.Getenv("K8S_API_CERT_EXPIRY_WARNING")
+	f.Service.APIClientCertFile = os.Getenv("K8S_API_CLIENT_CERT_FILE")
+	f.Service.APIClientKeyFile = os.Getenv("K8S_API_CLIENT_KEY_FILE")
+	f.Service.APIExpectedBody = os.Getenv("K8S_API_EXPECTED_BODY")
+	f.Service.APIExpectedStatus = os.Getenv("K8S_API_EXPECTED_STATUS")
+	f.Service.APIHost = os.Getenv("K8S_API_HOST")
+	f.Service.APILatencyWarning = os.Getenv("K8S_API_LATENCY_WARNING")
+	f.Service.APIPath = os.Getenv("K8S_API_PATH")
+	f.Service.APIPort = os.Getenv("K8S_API_PORT")
+	f.Service.APIScheme = os.Getenv("K8S_API_SCHEME")
+	f.Service.APIServerName = os.Getenv("K8S_API_SERVER_NAME")
+	f.Service.APITokenFile = os.Getenv("K8S_API_TOKEN_FILE")
+	f.Service.APIVerbose = os.Getenv("K8S_API_VERBOSE")
+	f.Service.AddressFile = os.Getenv("ADDRESS_FILE")
+	f.Service.AddressFixedIPs = os.Getenv("ADDRESS_FIXED_IPS")
+	f.Service.AddressOffset = os.Getenv("ADDRESS_OFFSET")
+	f.Service.AddressStrategy = os.Getenv("ADDRESS_STRATEGY")
+	f.Service.CheckAPI = os.Getenv("CHECK_K8S_API")
+	f.Service.CheckDependencies = os.Getenv("CHECK_DEPENDENCIES")
+	f.Service.CheckInterval = os.Getenv("CHECK_INTERVAL")
+	f.Service.CheckJitter = os.Getenv("CHECK_JITTER")
+	f.Service.CheckSeverities = os.Getenv("CHECK_SEVERITIES")
+	f.Service.Checks = os.Getenv("CHECKS")
+	f.Service.ChecksFile = os.Getenv("CHECKS_FILE")
+	f.Service.FailureThreshold = os.Getenv("FAILURE_THRESHOLD")
 	f.Service.FlannelFile = os.Getenv("NETWORK_ENV_FILE_PATH")
+	f.Service.FlannelWaitInterval = os.Getenv("NETWORK_ENV_FILE_WAIT_INTERVAL")
+	f.Service.FlannelWaitMaxInterval = os.Getenv("NETWORK_ENV_FILE_WAIT_MAX_INTERVAL")
+	f.Service.FlannelWaitTimeout = os.Getenv("NETWORK_ENV_FILE_WAIT_TIMEOUT")
+	f.Service.HealthzStatusCodes = os.Getenv("HEALTHZ_STATUS_CODES")
+	f.Service.HistorySize = os.Getenv("HISTORY_SIZE")
+	f.Service.IPFamily = os.Getenv("IP_FAMILY")
+	f.Service.KubeletExpectedBody = os.Getenv("KUBELET_EXPECTED_BODY")
+	f.Service.KubeletExpectedStatus = os.Getenv("KUBELET_EXPECTED_STATUS")
+	f.Service.KubeletHost = os.Getenv("KUBELET_HOST")
+	f.Service.KubeletPath = os.Getenv("KUBELET_PATH")
+	f.Service.KubeletPort = os.Getenv("KUBELET_PORT")
+	f.Service.KubeletScheme = os.Getenv("KUBELET_SCHEME")
 	f.Service.ListenAddress = os.Getenv("LISTEN_ADDRESS")
-	f.Service.CheckAPI = os.Getenv("CHECK_K8S_API")
+	f.Service.LivezChecks = os.Getenv("LIVEZ_CHECKS")
+	f.Service.LivezStatusCodes = os.Getenv("LIVEZ_STATUS_CODES")
+	f.Service.PingCount = os.Getenv("PING_COUNT")
+	f.Service.PingInterval = os.Getenv("PING_INTERVAL")
+	f.Service.PingMaxAvgRTT = os.Getenv("PING_MAX_AVG_RTT")
+	f.Service.PingMaxLoss = os.Getenv("PING_MAX_LOSS")
+	f.Service.PingMaxP99RTT = os.Getenv("PING_MAX_P99_RTT")
+	f.Service.PingMode = os.Getenv("PING_MODE")
+	f.Service.PingSize = os.Getenv("PING_SIZE")
+	f.Service.PingTCPPort = os.Getenv("PING_TCP_PORT")
+	f.Service.PingTimeout = os.Getenv("PING_TIMEOUT")
+	f.Service.ProbeBudget = os.Getenv("PROBE_BUDGET")
+	f.Service.ReadyzChecks = os.Getenv("READYZ_CHECKS")
+	f.Service.ReadyzStatusCodes = os.Getenv("READYZ_STATUS_CODES")
+	f.Service.StartupGracePeriod = os.Getenv("STARTUP_GRACE_PERIOD")
+	f.Service.StartupzChecks = os.Getenv("STARTUPZ_CHECKS")
+	f.Service.StartupzStatusCodes = os.Getenv("STARTUPZ_STATUS_CODES")
+	f.Service.StateFile = os.Getenv("STATE_FILE")
+	f.Service.StateMaxAge = os.Getenv("STATE_MAX_AGE")
+	f.Service.StateSaveInterval = os.Getenv("STATE_SAVE_INTERVAL")
+	f.Service.SuccessThreshold = os.Getenv("SUCCESS_THRESHOLD")
+	f.Service.WatchPollInterval = os.Getenv("WATCH_POLL_INTERVAL")
 	if f.Service.FlannelFile == "" {
 		return microerror.Maskf(invalidConfigError, "NETWORK_ENV_FILE_PATH must not be empty")
 	}
@@ -65,6 +125,7 @@ func mainWithError() error {
 		if err != nil {
 			panic(err)
 		}
+
 		// Create a new custom service which implements business logic.
 		var newService *service.Service
 		{
@@ -99,6 +160,19 @@ func mainWithError() error {
 			if err != nil {
 				panic(err)
 			}
+
+			// microkit only takes the config of the returned server to create its
+			// own server, so the custom boot logic of our server has to be
+			// triggered here.
+			newServer.Boot()
+
+			// For the same reason the custom shutdown logic of our server, which
+			// stops the background work of the service, e.g. waiting for the
+			// flannel file, and persists the final health state, has to be
+			// triggered on the signals microkit shuts down on. microkit may exit
+			// before it finished, changes of the health state are saved right
+			// away though.
+			server.ShutdownOnSignal(newServer, syscall.SIGINT, syscall.SIGTERM)
 		}
 
 		return newServer
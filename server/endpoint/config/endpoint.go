@@ -0,0 +1,151 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	kitendpoint "github.com/go-kit/kit/endpoint"
+	kithttp "github.com/go-kit/kit/transport/http"
+
+	"github.com/giantswarm/k8s-kvm-health/service/healthz"
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check"
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/probe"
+)
+
+const (
+	// Method is the HTTP method this endpoint is registered for.
+	Method = "GET"
+	// Name identifies the endpoint. It is aligned to the package path.
+	Name = "config"
+	// Path is the HTTP request path this endpoint is registered for.
+	Path = "/config"
+)
+
+// Config represents the configuration used to create a config endpoint.
+type Config struct {
+	// Dependencies.
+	Logger  micrologger.Logger
+	Service *healthz.Service
+}
+
+// DefaultConfig provides a default configuration to create a new config
+// endpoint by best effort.
+func DefaultConfig() Config {
+	return Config{
+		// Dependencies.
+		Logger:  nil,
+		Service: nil,
+	}
+}
+
+// New creates a new configured config endpoint. It exposes the effective
+// configuration of the checks and which of them every probe performs.
+func New(config Config) (*Endpoint, error) {
+	// Dependencies.
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "logger must not be empty")
+	}
+	if config.Service == nil {
+		return nil, microerror.Maskf(invalidConfigError, "service must not be empty")
+	}
+
+	newEndpoint := &Endpoint{
+		Config: config,
+	}
+
+	return newEndpoint, nil
+}
+
+type Endpoint struct {
+	Config
+}
+
+func (e *Endpoint) Decoder() kithttp.DecodeRequestFunc {
+	return func(ctx context.Context, r *http.Request) (interface{}, error) {
+		return nil, nil
+	}
+}
+
+func (e *Endpoint) Encoder() kithttp.EncodeResponseFunc {
+	return func(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+		return json.NewEncoder(w).Encode(response)
+	}
+}
+
+func (e *Endpoint) Endpoint() kitendpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		kvmService := e.Service.KVM
+
+		enabled := map[string]bool{}
+		for _, name := range kvmService.Checks() {
+			enabled[name] = true
+		}
+
+		checkers, err := kvmService.Registry().Enabled(kvmService.Registry().Names())
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		response := DefaultResponse()
+		for _, c := range checkers {
+			r := Check{
+				Name:        c.Name(),
+				Description: c.Description(),
+				Enabled:     enabled[c.Name()],
+				Severity:    string(kvmService.Registry().Severity(c.Name())),
+			}
+			if configurable, ok := c.(check.Configurable); ok {
+				r.Settings = configurable.Settings()
+			}
+
+			response.Checks = append(response.Checks, r)
+		}
+
+		for _, p := range []*probe.Service{e.Service.Liveness, e.Service.Readiness, e.Service.Startup} {
+			checks := p.Checks()
+			if checks == nil {
+				checks = []string{}
+			}
+
+			response.Probes[p.Name()] = checks
+		}
+
+		statusCodes := e.Service.StatusCodes
+		for name, codes := range map[string]check.StatusCodes{
+			"healthz":  statusCodes.Healthz,
+			"livez":    statusCodes.Livez,
+			"readyz":   statusCodes.Readyz,
+			"startupz": statusCodes.Startupz,
+		} {
+			effective := check.StatusCodes{}
+			for _, state := range []check.State{check.StateHealthy, check.StateInitializing, check.StateDegraded, check.StateUnhealthy} {
+				effective[state] = codes.Code(state)
+			}
+
+			response.StatusCodes[name] = effective
+		}
+
+		return response, nil
+	}
+}
+
+func (e *Endpoint) Method() string {
+	return Method
+}
+
+func (e *Endpoint) Middlewares() []kitendpoint.Middleware {
+	return []kitendpoint.Middleware{}
+}
+
+func (e *Endpoint) Name() string {
+	return Name
+}
+
+func (e *Endpoint) Path() string {
+	return Path
+}
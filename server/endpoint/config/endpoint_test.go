@@ -0,0 +1,147 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+
+	"github.com/giantswarm/k8s-kvm-health/service/healthz"
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check"
+)
+
+func Test_Config_Endpoint(t *testing.T) {
+	healthzService, err := healthz.New(healthz.Config{
+		Logger: microloggertest.New(),
+
+		Checks: []string{healthz.CheckPing, healthz.CheckKubelet},
+		Kubelet: healthz.HTTPConfig{
+			Path: "/readyz",
+			Port: 10250,
+		},
+		ReadinessChecks: []string{healthz.CheckPing, healthz.CheckKubelet},
+		Severities: map[string]check.Severity{
+			healthz.CheckKubelet: check.SeverityWarning,
+		},
+		StartupChecks: []string{healthz.CheckPing},
+		StatusCodes: healthz.StatusCodes{
+			Readyz: check.StatusCodes{check.StateDegraded: 503},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := New(Config{
+		Logger:  microloggertest.New(),
+		Service: healthzService,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := e.Endpoint()(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	err = e.Encoder()(context.Background(), w, response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var r struct {
+		Checks []struct {
+			Name     string                 `json:"name"`
+			Enabled  bool                   `json:"enabled"`
+			Severity string                 `json:"severity"`
+			Settings map[string]interface{} `json:"settings"`
+		} `json:"checks"`
+		Probes      map[string][]string       `json:"probes"`
+		StatusCodes map[string]map[string]int `json:"status_codes"`
+	}
+	err = json.Unmarshal(w.Body.Bytes(), &r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name             string
+		expectedEnabled  bool
+		expectedSeverity string
+		expectedPath     interface{}
+		expectedPort     interface{}
+	}{
+		// test 0 - ping reports its own settings
+		{
+			name:             healthz.CheckPing,
+			expectedEnabled:  true,
+			expectedSeverity: "critical",
+		},
+		// test 1 - kubelet with the configured endpoint and severity
+		{
+			name:             healthz.CheckKubelet,
+			expectedEnabled:  true,
+			expectedSeverity: "warning",
+			expectedPath:     "/readyz",
+			expectedPort:     float64(10250),
+		},
+		// test 2 - api registered but not enabled, with its defaults
+		{
+			name:             healthz.CheckAPI,
+			expectedEnabled:  false,
+			expectedSeverity: "critical",
+			expectedPath:     "/healthz",
+			expectedPort:     float64(443),
+		},
+	}
+
+	if len(r.Checks) != len(tests) {
+		t.Fatalf("expected %d checks got %#v", len(tests), r.Checks)
+	}
+	for index, test := range tests {
+		c := r.Checks[index]
+
+		if c.Name != test.name {
+			t.Fatalf("%d: expected name %s got %s", index, test.name, c.Name)
+		}
+		if c.Enabled != test.expectedEnabled {
+			t.Fatalf("%d: expected enabled %t got %t", index, test.expectedEnabled, c.Enabled)
+		}
+		if c.Severity != test.expectedSeverity {
+			t.Fatalf("%d: expected severity %s got %s", index, test.expectedSeverity, c.Severity)
+		}
+		if c.Settings == nil {
+			t.Fatalf("%d: expected settings", index)
+		}
+		if c.Settings["path"] != test.expectedPath {
+			t.Fatalf("%d: expected path %v got %v", index, test.expectedPath, c.Settings["path"])
+		}
+		if c.Settings["port"] != test.expectedPort {
+			t.Fatalf("%d: expected port %v got %v", index, test.expectedPort, c.Settings["port"])
+		}
+	}
+
+	expectedProbes := map[string][]string{
+		"livez":    {},
+		"readyz":   {healthz.CheckPing, healthz.CheckKubelet},
+		"startupz": {healthz.CheckPing},
+	}
+	if !reflect.DeepEqual(r.Probes, expectedProbes) {
+		t.Fatalf("expected probes %v got %v", expectedProbes, r.Probes)
+	}
+
+	expectedStatusCodes := map[string]map[string]int{
+		"healthz":  {"healthy": 200, "initializing": 200, "degraded": 200, "unhealthy": 500},
+		"livez":    {"healthy": 200, "initializing": 200, "degraded": 200, "unhealthy": 500},
+		"readyz":   {"healthy": 200, "initializing": 200, "degraded": 503, "unhealthy": 500},
+		"startupz": {"healthy": 200, "initializing": 200, "degraded": 200, "unhealthy": 500},
+	}
+	if !reflect.DeepEqual(r.StatusCodes, expectedStatusCodes) {
+		t.Fatalf("expected status codes %v got %v", expectedStatusCodes, r.StatusCodes)
+	}
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check"
+)
+
+// Response is the return value of the config endpoint.
+type Response struct {
+	// Checks are all known checks in the order they are registered.
+	Checks []Check `json:"checks"`
+	// Probes maps the name of every probe to the checks it performs.
+	Probes map[string][]string `json:"probes"`
+	// StatusCodes maps the name of every health endpoint to the status codes
+	// it serves the overall health with.
+	StatusCodes map[string]check.StatusCodes `json:"status_codes"`
+}
+
+// Check is the configuration of a single check.
+type Check struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Enabled     bool        `json:"enabled"`
+	Severity    string      `json:"severity"`
+	Settings    interface{} `json:"settings,omitempty"`
+}
+
+// DefaultResponse provides a default response object by best effort.
+func DefaultResponse() *Response {
+	return &Response{
+		Checks:      []Check{},
+		Probes:      map[string][]string{},
+		StatusCodes: map[string]check.StatusCodes{},
+	}
+}
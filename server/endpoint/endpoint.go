@@ -1,12 +1,14 @@
 package endpoint
 
 import (
-	"github.com/giantswarm/microendpoint/endpoint/healthz"
 	"github.com/giantswarm/microendpoint/endpoint/version"
-	healthzservice "github.com/giantswarm/microendpoint/service/healthz"
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger"
 
+	configendpoint "github.com/giantswarm/k8s-kvm-health/server/endpoint/config"
+	"github.com/giantswarm/k8s-kvm-health/server/endpoint/healthz"
+	"github.com/giantswarm/k8s-kvm-health/server/endpoint/history"
+	"github.com/giantswarm/k8s-kvm-health/server/endpoint/target"
 	"github.com/giantswarm/k8s-kvm-health/server/middleware"
 	"github.com/giantswarm/k8s-kvm-health/service"
 )
@@ -32,27 +34,132 @@ func DefaultConfig() Config {
 
 // Endpoint is the endpoint collection.
 type Endpoint struct {
-	Healthz *healthz.Endpoint
-	Version *version.Endpoint
+	Config   *configendpoint.Endpoint
+	Healthz  *healthz.Endpoint
+	History  *history.Endpoint
+	Livez    *healthz.Endpoint
+	Readyz   *healthz.Endpoint
+	Startupz *healthz.Endpoint
+	Target   *target.Endpoint
+	Version  *version.Endpoint
 }
 
 // New creates a new configured endpoint.
 func New(config Config) (*Endpoint, error) {
 	var err error
 
+	var configEndpoint *configendpoint.Endpoint
+	{
+		configConfig := configendpoint.DefaultConfig()
+		configConfig.Logger = config.Logger
+		configConfig.Service = config.Service.Healthz
+		configEndpoint, err = configendpoint.New(configConfig)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
 	var healthzEndpoint *healthz.Endpoint
 	{
 		healthzConfig := healthz.DefaultConfig()
 		healthzConfig.Logger = config.Logger
-		healthzConfig.Services = []healthzservice.Service{
-			config.Service.Healthz.KVM,
+		healthzConfig.Target = config.Service.Healthz.KVM
+		healthzConfig.Version = config.Service.Version
+		// every enabled checker is reported on its own, without any the kvm
+		// health check still reports whether it is initializing
+		for _, c := range config.Service.Healthz.KVM.Checkers() {
+			healthzConfig.Services = append(healthzConfig.Services, c)
+		}
+		if len(healthzConfig.Services) == 0 {
+			healthzConfig.Services = []healthz.Service{
+				config.Service.Healthz.KVM,
+			}
 		}
+		healthzConfig.Name = healthz.HealthzName
+		healthzConfig.Path = healthz.HealthzPath
+		healthzConfig.StatusCodes = config.Service.Healthz.StatusCodes.Healthz
 		healthzEndpoint, err = healthz.New(healthzConfig)
 		if err != nil {
 			return nil, microerror.Mask(err)
 		}
 	}
 
+	var historyEndpoint *history.Endpoint
+	{
+		historyConfig := history.DefaultConfig()
+		historyConfig.Logger = config.Logger
+		historyConfig.Service = config.Service.Healthz.KVM
+		historyEndpoint, err = history.New(historyConfig)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	var livezEndpoint *healthz.Endpoint
+	{
+		livezConfig := healthz.DefaultConfig()
+		livezConfig.Logger = config.Logger
+		livezConfig.Target = config.Service.Healthz.KVM
+		livezConfig.Version = config.Service.Version
+		livezConfig.Services = []healthz.Service{
+			config.Service.Healthz.Liveness,
+		}
+		livezConfig.Name = healthz.LivezName
+		livezConfig.Path = healthz.LivezPath
+		livezConfig.StatusCodes = config.Service.Healthz.StatusCodes.Livez
+		livezEndpoint, err = healthz.New(livezConfig)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	var readyzEndpoint *healthz.Endpoint
+	{
+		readyzConfig := healthz.DefaultConfig()
+		readyzConfig.Logger = config.Logger
+		readyzConfig.Target = config.Service.Healthz.KVM
+		readyzConfig.Version = config.Service.Version
+		readyzConfig.Services = []healthz.Service{
+			config.Service.Healthz.Readiness,
+		}
+		readyzConfig.Name = healthz.ReadyzName
+		readyzConfig.Path = healthz.ReadyzPath
+		readyzConfig.StatusCodes = config.Service.Healthz.StatusCodes.Readyz
+		readyzEndpoint, err = healthz.New(readyzConfig)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	var startupzEndpoint *healthz.Endpoint
+	{
+		startupzConfig := healthz.DefaultConfig()
+		startupzConfig.Logger = config.Logger
+		startupzConfig.Target = config.Service.Healthz.KVM
+		startupzConfig.Version = config.Service.Version
+		startupzConfig.Services = []healthz.Service{
+			config.Service.Healthz.Startup,
+		}
+		startupzConfig.Name = healthz.StartupzName
+		startupzConfig.Path = healthz.StartupzPath
+		startupzConfig.StatusCodes = config.Service.Healthz.StatusCodes.Startupz
+		startupzEndpoint, err = healthz.New(startupzConfig)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	var targetEndpoint *target.Endpoint
+	{
+		targetConfig := target.DefaultConfig()
+		targetConfig.Logger = config.Logger
+		targetConfig.Service = config.Service.Healthz.KVM
+		targetEndpoint, err = target.New(targetConfig)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
 	var versionEndpoint *version.Endpoint
 	{
 		versionConfig := version.DefaultConfig()
@@ -65,8 +172,14 @@ func New(config Config) (*Endpoint, error) {
 	}
 
 	newEndpoint := &Endpoint{
-		Healthz: healthzEndpoint,
-		Version: versionEndpoint,
+		Config:   configEndpoint,
+		Healthz:  healthzEndpoint,
+		History:  historyEndpoint,
+		Livez:    livezEndpoint,
+		Readyz:   readyzEndpoint,
+		Startupz: startupzEndpoint,
+		Target:   targetEndpoint,
+		Version:  versionEndpoint,
 	}
 
 	return newEndpoint, nil
@@ -0,0 +1,273 @@
+package healthz
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/giantswarm/microendpoint/service/version"
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	kitendpoint "github.com/go-kit/kit/endpoint"
+	kithttp "github.com/go-kit/kit/transport/http"
+
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check"
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/kvm"
+)
+
+const (
+	// Method is the HTTP method this endpoint is registered for.
+	Method = "GET"
+
+	// HealthzName and HealthzPath are used for the general health endpoint.
+	HealthzName = "healthz"
+	HealthzPath = "/healthz"
+	// LivezName and LivezPath are used for the endpoint backing the liveness
+	// probe.
+	LivezName = "livez"
+	LivezPath = "/livez"
+	// ReadyzName and ReadyzPath are used for the endpoint backing the readiness
+	// probe.
+	ReadyzName = "readyz"
+	ReadyzPath = "/readyz"
+	// StartupzName and StartupzPath are used for the endpoint backing the
+	// startup probe.
+	StartupzName = "startupz"
+	StartupzPath = "/startupz"
+)
+
+// Service is implemented by health checks reporting the results of their
+// single checks.
+type Service interface {
+	GetHealthzResponse(ctx context.Context) (check.Response, error)
+}
+
+// TargetService tells which KVM is probed.
+type TargetService interface {
+	Target() kvm.Target
+}
+
+// VersionService describes the running k8s-kvm-health.
+type VersionService interface {
+	Get(ctx context.Context, request version.Request) (*version.Response, error)
+}
+
+// Config represents the configuration used to create a healthz endpoint.
+type Config struct {
+	// Dependencies.
+	Logger   micrologger.Logger
+	Services []Service
+	Target   TargetService
+	Version  VersionService
+
+	// Settings.
+	Name string
+	Path string
+	// StatusCodes maps the overall health of the responses to the HTTP status
+	// code they are served with. Defaults to check.DefaultStatusCodes.
+	StatusCodes check.StatusCodes
+}
+
+// DefaultConfig provides a default configuration to create a new healthz
+// endpoint by best effort.
+func DefaultConfig() Config {
+	return Config{
+		// Dependencies.
+		Logger:   nil,
+		Services: nil,
+		Target:   nil,
+		Version:  nil,
+
+		// Settings.
+		Name:        "",
+		Path:        "",
+		StatusCodes: nil,
+	}
+}
+
+// New creates a new configured healthz endpoint registered for the given path.
+// It responds with the overall health, the probed target, the results of the
+// single checks and the build, as documented by Response. Clients accepting
+// text/plain get the same in a format meant to be read by humans.
+func New(config Config) (*Endpoint, error) {
+	// Dependencies.
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "logger must not be empty")
+	}
+	if len(config.Services) == 0 {
+		return nil, microerror.Maskf(invalidConfigError, "services must not be empty")
+	}
+	if config.Target == nil {
+		return nil, microerror.Maskf(invalidConfigError, "target must not be empty")
+	}
+	if config.Version == nil {
+		return nil, microerror.Maskf(invalidConfigError, "version must not be empty")
+	}
+
+	// Settings.
+	if config.Name == "" {
+		return nil, microerror.Maskf(invalidConfigError, "name must not be empty")
+	}
+	if config.Path == "" {
+		return nil, microerror.Maskf(invalidConfigError, "path must not be empty")
+	}
+
+	newEndpoint := &Endpoint{
+		Config: config,
+	}
+
+	return newEndpoint, nil
+}
+
+type Endpoint struct {
+	Config
+}
+
+func (e *Endpoint) Decoder() kithttp.DecodeRequestFunc {
+	return func(ctx context.Context, r *http.Request) (interface{}, error) {
+		request := Request{
+			Fresh:  isTrue(r.URL.Query().Get("fresh")),
+			format: negotiateFormat(r.Header.Get("Accept")),
+		}
+
+		return request, nil
+	}
+}
+
+func (e *Endpoint) Encoder() kithttp.EncodeResponseFunc {
+	return func(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+		r, ok := response.(*Response)
+		if !ok {
+			return microerror.Maskf(wrongTypeError, "expected '%T' got '%T'", &Response{}, response)
+		}
+
+		for _, h := range r.HealthChecks {
+			if h.Failed {
+				e.Logger.Log("error", "health check failed", "healthCheckDescription", h.Description, "healthCheckMessage", h.Message) // nolint
+			}
+		}
+
+		if r.format == formatText {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		} else {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		}
+
+		code := e.StatusCodes.Code(codeStatus(r))
+		if code != http.StatusOK {
+			w.WriteHeader(code)
+		}
+
+		if r.format == formatText {
+			return writeText(w, r)
+		}
+
+		return json.NewEncoder(w).Encode(r)
+	}
+}
+
+func (e *Endpoint) Endpoint() kitendpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		r, ok := request.(Request)
+		if ok && r.Fresh {
+			ctx = check.WithFresh(ctx)
+		}
+		// checkers shared by several services, e.g. dependencies, are only
+		// performed once
+		ctx = kvm.WithRun(ctx)
+
+		// the services are asked concurrently so that the slowest of them
+		// determines the response time
+		responses := make([]check.Response, len(e.Services))
+		errs := make([]error, len(e.Services))
+		var wg sync.WaitGroup
+		for i, s := range e.Services {
+			wg.Add(1)
+			go func(i int, s Service) {
+				defer wg.Done()
+				responses[i], errs[i] = s.GetHealthzResponse(ctx)
+			}(i, s)
+		}
+		wg.Wait()
+
+		for _, err := range errs {
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+		}
+
+		build, err := e.Version.Get(ctx, version.DefaultRequest())
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		response := DefaultResponse()
+		response.Name = e.Config.Name
+		response.Timestamp = time.Now().UTC()
+		response.format = r.format
+
+		target := e.Target.Target()
+		if len(target.IPs) != 0 {
+			response.Target.IPs = target.IPs
+		}
+		response.Target.Source = target.Source
+		for _, n := range target.Networks {
+			response.Target.Networks = append(response.Target.Networks, n.String())
+		}
+		response.Target.MTU = target.MTU
+		if !target.LastChanged.IsZero() {
+			lastChanged := target.LastChanged
+			response.Target.LastChanged = &lastChanged
+		}
+
+		for _, cr := range responses {
+			response.HealthChecks = append(response.HealthChecks, HealthCheck{
+				Name:        cr.Name,
+				Description: cr.Description,
+				Status:      statusOf(cr),
+				Failed:      cr.Failed,
+				Message:     cr.Message,
+			})
+			response.Checks = append(response.Checks, cr.Checks...)
+		}
+		response.Status, response.Message = worstStatus(response.HealthChecks)
+
+		response.Build = Build{
+			Name:        build.Name,
+			Description: build.Description,
+			GitCommit:   build.GitCommit,
+			GoVersion:   build.GoVersion,
+			OSArch:      build.OSArch,
+			Source:      build.Source,
+		}
+
+		return response, nil
+	}
+}
+
+func (e *Endpoint) Method() string {
+	return Method
+}
+
+func (e *Endpoint) Middlewares() []kitendpoint.Middleware {
+	return []kitendpoint.Middleware{}
+}
+
+func (e *Endpoint) Name() string {
+	return e.Config.Name
+}
+
+func (e *Endpoint) Path() string {
+	return e.Config.Path
+}
+
+func isTrue(s string) bool {
+	switch s {
+	case "1", "true":
+		return true
+	default:
+		return false
+	}
+}
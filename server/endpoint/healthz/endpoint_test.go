@@ -0,0 +1,274 @@
+package healthz
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/microendpoint/service/healthz"
+	"github.com/giantswarm/microendpoint/service/version"
+	"github.com/giantswarm/micrologger/microloggertest"
+
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check"
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/kvm"
+)
+
+type testService struct {
+	response check.Response
+}
+
+func (s *testService) GetHealthzResponse(ctx context.Context) (check.Response, error) {
+	return s.response, nil
+}
+
+type testTarget struct{}
+
+func (t *testTarget) Target() kvm.Target {
+	return kvm.Target{
+		IPs:    []string{"172.23.3.66"},
+		Source: "/run/flannel/networks/br-test.env",
+	}
+}
+
+type testVersion struct{}
+
+func (v *testVersion) Get(ctx context.Context, request version.Request) (*version.Response, error) {
+	return &version.Response{
+		Name:      "k8s-kvm-health",
+		GitCommit: "4c0843e",
+	}, nil
+}
+
+func Test_Endpoint_Encoder(t *testing.T) {
+	tests := []struct {
+		failed             bool
+		state              check.State
+		statusCodes        check.StatusCodes
+		expectedStatus     check.State
+		expectedStatusCode int
+	}{
+		// test 0 - succeeded
+		{
+			failed:             false,
+			expectedStatus:     check.StateHealthy,
+			expectedStatusCode: http.StatusOK,
+		},
+		// test 1 - failed
+		{
+			failed:             true,
+			expectedStatus:     check.StateUnhealthy,
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+		// test 2 - degraded passes by default
+		{
+			failed:             false,
+			state:              check.StateDegraded,
+			expectedStatus:     check.StateDegraded,
+			expectedStatusCode: http.StatusOK,
+		},
+		// test 3 - degraded mapped to a custom status code
+		{
+			failed:             false,
+			state:              check.StateDegraded,
+			statusCodes:        check.StatusCodes{check.StateDegraded: http.StatusMultiStatus},
+			expectedStatus:     check.StateDegraded,
+			expectedStatusCode: http.StatusMultiStatus,
+		},
+		// test 4 - unhealthy mapped to a custom status code
+		{
+			failed:             true,
+			state:              check.StateUnhealthy,
+			statusCodes:        check.StatusCodes{check.StateUnhealthy: http.StatusServiceUnavailable},
+			expectedStatus:     check.StateUnhealthy,
+			expectedStatusCode: http.StatusServiceUnavailable,
+		},
+		// test 5 - initializing within the grace period passes
+		{
+			failed:             false,
+			state:              check.StateInitializing,
+			expectedStatus:     check.StateInitializing,
+			expectedStatusCode: http.StatusOK,
+		},
+		// test 6 - initializing counted as failure is served like unhealthy
+		{
+			failed:             true,
+			state:              check.StateInitializing,
+			statusCodes:        check.StatusCodes{check.StateUnhealthy: http.StatusServiceUnavailable},
+			expectedStatus:     check.StateInitializing,
+			expectedStatusCode: http.StatusServiceUnavailable,
+		},
+	}
+
+	for index, test := range tests {
+		service := &testService{
+			response: check.Response{
+				Response: healthz.Response{
+					Description: "test",
+					Failed:      test.failed,
+					Name:        "test",
+				},
+				State: test.state,
+				Checks: []check.Result{
+					{
+						Name:      "ping",
+						Target:    "172.23.3.66",
+						Status:    check.StatusOK,
+						Latency:   1500 * time.Microsecond,
+						Timestamp: time.Now(),
+					},
+				},
+			},
+		}
+
+		e, err := New(Config{
+			Logger:   microloggertest.New(),
+			Services: []Service{service},
+			Target:   &testTarget{},
+			Version:  &testVersion{},
+			Name:     HealthzName,
+			Path:     HealthzPath,
+
+			StatusCodes: test.statusCodes,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		response, err := e.Endpoint()(context.Background(), nil)
+		if err != nil {
+			t.Fatalf("%d: unexpected error %#v", index, err)
+		}
+
+		w := httptest.NewRecorder()
+		err = e.Encoder()(context.Background(), w, response)
+		if err != nil {
+			t.Fatalf("%d: unexpected error %#v", index, err)
+		}
+		if w.Code != test.expectedStatusCode {
+			t.Fatalf("%d: expected status code %d got %d", index, test.expectedStatusCode, w.Code)
+		}
+
+		var body map[string]interface{}
+		err = json.Unmarshal(w.Body.Bytes(), &body)
+		if err != nil {
+			t.Fatalf("%d: unexpected error %#v", index, err)
+		}
+		if body["schemaVersion"] != float64(SchemaVersion) {
+			t.Fatalf("%d: expected schema version %d got %v", index, SchemaVersion, body["schemaVersion"])
+		}
+		if body["status"] != string(test.expectedStatus) {
+			t.Fatalf("%d: expected status %s got %v", index, test.expectedStatus, body["status"])
+		}
+		if source := body["target"].(map[string]interface{})["source"]; source != "/run/flannel/networks/br-test.env" {
+			t.Fatalf("%d: expected target source got %v", index, source)
+		}
+		if lastChanged, ok := body["target"].(map[string]interface{})["last_changed"]; ok {
+			t.Fatalf("%d: expected last changed of unchanged target to be omitted got %v", index, lastChanged)
+		}
+		if commit := body["build"].(map[string]interface{})["git_commit"]; commit != "4c0843e" {
+			t.Fatalf("%d: expected git commit got %v", index, commit)
+		}
+		checks, ok := body["checks"].([]interface{})
+		if !ok || len(checks) != 1 {
+			t.Fatalf("%d: expected one check got %#v", index, body["checks"])
+		}
+		if latency := checks[0].(map[string]interface{})["latency_ms"]; latency != 1.5 {
+			t.Fatalf("%d: expected latency 1.5 got %v", index, latency)
+		}
+	}
+}
+
+func Test_Endpoint_Format(t *testing.T) {
+	tests := []struct {
+		accept              string
+		expectedContentType string
+	}{
+		// test 0 - JSON by default
+		{
+			accept:              "",
+			expectedContentType: "application/json; charset=utf-8",
+		},
+		// test 1 - JSON for any media type
+		{
+			accept:              "*/*",
+			expectedContentType: "application/json; charset=utf-8",
+		},
+		// test 2 - plain text for humans
+		{
+			accept:              "text/plain",
+			expectedContentType: "text/plain; charset=utf-8",
+		},
+		// test 3 - the supported media type of the highest quality wins
+		{
+			accept:              "text/html, text/plain;q=0.9, application/json;q=0.8",
+			expectedContentType: "text/plain; charset=utf-8",
+		},
+		// test 4 - the quality wins over the order
+		{
+			accept:              "text/plain;q=0.1, application/json",
+			expectedContentType: "application/json; charset=utf-8",
+		},
+		// test 5 - media types of quality 0 are not acceptable
+		{
+			accept:              "application/json;q=0, text/*;q=0.5",
+			expectedContentType: "text/plain; charset=utf-8",
+		},
+		// test 6 - the first media type wins on equal quality
+		{
+			accept:              "text/plain, */*",
+			expectedContentType: "text/plain; charset=utf-8",
+		},
+	}
+
+	for index, test := range tests {
+		e, err := New(Config{
+			Logger: microloggertest.New(),
+			Services: []Service{
+				&testService{
+					response: check.Response{
+						Response: healthz.Response{Name: "test"},
+						Checks:   []check.Result{{Name: "ping", Target: "172.23.3.66", Status: check.StatusOK}},
+					},
+				},
+			},
+			Target:  &testTarget{},
+			Version: &testVersion{},
+			Name:    ReadyzName,
+			Path:    ReadyzPath,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r := httptest.NewRequest("GET", ReadyzPath, nil)
+		if test.accept != "" {
+			r.Header.Set("Accept", test.accept)
+		}
+
+		request, err := e.Decoder()(context.Background(), r)
+		if err != nil {
+			t.Fatalf("%d: unexpected error %#v", index, err)
+		}
+		response, err := e.Endpoint()(context.Background(), request)
+		if err != nil {
+			t.Fatalf("%d: unexpected error %#v", index, err)
+		}
+
+		w := httptest.NewRecorder()
+		err = e.Encoder()(context.Background(), w, response)
+		if err != nil {
+			t.Fatalf("%d: unexpected error %#v", index, err)
+		}
+
+		if contentType := w.Header().Get("Content-Type"); contentType != test.expectedContentType {
+			t.Fatalf("%d: expected content type %q got %q", index, test.expectedContentType, contentType)
+		}
+		if test.expectedContentType == "text/plain; charset=utf-8" && !strings.HasPrefix(w.Body.String(), "readyz: healthy\n") {
+			t.Fatalf("%d: expected plain text got %q", index, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package healthz
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/giantswarm/microerror"
+)
+
+// format is the encoding of a response.
+type format int
+
+const (
+	formatJSON format = iota
+	formatText
+)
+
+// negotiateFormat returns the supported format of the media range of the
+// given Accept header with the highest quality. Ranges of equal quality are
+// preferred in the order they are given, ranges of quality 0 are not
+// acceptable. JSON is the default, so that clients not asking for a specific
+// format keep getting JSON.
+func negotiateFormat(accept string) format {
+	f := formatJSON
+	quality := 0.0
+
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+		}
+		if q <= quality {
+			continue
+		}
+
+		switch mediaType {
+		case "application/json", "application/*", "*/*":
+			f, quality = formatJSON, q
+		case "text/plain", "text/*":
+			f, quality = formatText, q
+		}
+	}
+
+	return f
+}
+
+// writeText writes the given response in a format meant to be read by humans,
+// e.g.
+//
+//	readyz: degraded
+//	message: Degraded by warning check api. ...
+//	target: 172.23.3.66 from /run/flannel/networks/br-1a2b3c.env
+//
+//	CHECK  TARGET       STATUS  STATE      LATENCY  MESSAGE
+//	ping   172.23.3.66  ok      healthy    0.3ms    KVM is live and responding.
+//	api    172.23.3.66  failed  unhealthy  0.5ms    connection refused
+//
+//	build: k8s-kvm-health 4c0843e go1.14 linux/amd64
+func writeText(w io.Writer, r *Response) error {
+	target := strings.Join(r.Target.IPs, ", ")
+	if target == "" {
+		target = "unknown"
+	}
+	if r.Target.Source != "" {
+		target = fmt.Sprintf("%s from %s", target, r.Target.Source)
+	}
+
+	_, err := fmt.Fprintf(w, "%s: %s\nmessage: %s\ntarget: %s\n", r.Name, r.Status, r.Message, target)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if len(r.Checks) != 0 {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprint(tw, "\nCHECK\tTARGET\tSTATUS\tSTATE\tLATENCY\tMESSAGE\n") // nolint
+		for _, c := range r.Checks {
+			message := c.Message
+			if c.Failed() {
+				message = c.Error
+			}
+			if c.Warning != "" {
+				message = strings.TrimSpace(fmt.Sprintf("%s %s", message, c.Warning))
+			}
+
+			state := c.State
+			if state == "" {
+				state = "-"
+			}
+
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", c.Name, c.Target, c.Status, state, c.Latency.Round(100*time.Microsecond), message) // nolint
+		}
+		err = tw.Flush()
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	_, err = fmt.Fprintf(w, "\nbuild: %s %s %s %s\n", r.Build.Name, r.Build.GitCommit, r.Build.GoVersion, r.Build.OSArch)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,13 @@
+package healthz
+
+// Request is the decoded request of the healthz endpoint.
+type Request struct {
+	// Fresh asks for the checks to be performed synchronously instead of
+	// serving cached results. It is set by the fresh query parameter, e.g.
+	// /healthz?fresh=1.
+	Fresh bool
+
+	// format is the format of the response negotiated using the Accept
+	// header.
+	format format
+}
@@ -0,0 +1,130 @@
+package healthz
+
+import (
+	"time"
+
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check"
+)
+
+// SchemaVersion is the version of the JSON schema of the responses of the
+// health endpoints. It is increased on every incompatible change of Response.
+const SchemaVersion = 1
+
+// Response is the return value of the health endpoints.
+type Response struct {
+	// SchemaVersion is the version of the schema of the response.
+	SchemaVersion int `json:"schemaVersion"`
+	// Name is the name of the health endpoint, e.g. readyz.
+	Name string `json:"name"`
+	// Status is the overall health, the worst status of the HealthChecks. It
+	// determines the HTTP status code of the response.
+	Status check.State `json:"status"`
+	// Message is the message of the first health check of the worst status.
+	Message   string    `json:"message"`
+	Timestamp time.Time `json:"timestamp"`
+	// Target is the KVM probed.
+	Target Target `json:"target"`
+	// HealthChecks are the responses of the health services of the endpoint,
+	// e.g. one for every check on /healthz.
+	HealthChecks []HealthCheck `json:"health_checks"`
+	// Checks are the results of the single checks of all HealthChecks.
+	Checks []check.Result `json:"checks"`
+	// Build describes the running k8s-kvm-health.
+	Build Build `json:"build"`
+
+	// format is the format the response is encoded in, as negotiated with the
+	// client.
+	format format
+}
+
+// HealthCheck is the response of a single health service.
+type HealthCheck struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Status      check.State `json:"status"`
+	Failed      bool        `json:"failed"`
+	Message     string      `json:"message"`
+}
+
+// Target describes the KVM probed.
+type Target struct {
+	IPs []string `json:"ips"`
+	// Source is the file the IPs were derived from.
+	Source   string   `json:"source,omitempty"`
+	Networks []string `json:"networks,omitempty"`
+	MTU      int      `json:"mtu,omitempty"`
+	// LastChanged is the time the target was last changed. It is omitted as
+	// long as no target is known.
+	LastChanged *time.Time `json:"last_changed,omitempty"`
+}
+
+// Build describes the running k8s-kvm-health, as served by the version
+// endpoint.
+type Build struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	GitCommit   string `json:"git_commit"`
+	GoVersion   string `json:"go_version"`
+	OSArch      string `json:"os_arch"`
+	Source      string `json:"source"`
+}
+
+// DefaultResponse provides a default response object by best effort.
+func DefaultResponse() *Response {
+	return &Response{
+		SchemaVersion: SchemaVersion,
+		HealthChecks:  []HealthCheck{},
+		Checks:        []check.Result{},
+		Target: Target{
+			IPs: []string{},
+		},
+	}
+}
+
+// statusOf returns the status of the given health service response. Failed
+// responses are unhealthy whatever their state, unless they are initializing,
+// see codeStatus.
+func statusOf(r check.Response) check.State {
+	if r.State == check.StateInitializing {
+		return check.StateInitializing
+	}
+	if r.Failed {
+		return check.StateUnhealthy
+	}
+	if r.State == "" {
+		return check.StateHealthy
+	}
+
+	return r.State
+}
+
+// codeStatus returns the status the HTTP status code of the given response is
+// looked up for. Initializing responses are served like unhealthy ones once
+// initializing counts as failed, e.g. after the startup grace period.
+func codeStatus(r *Response) check.State {
+	if r.Status != check.StateInitializing {
+		return r.Status
+	}
+	for _, h := range r.HealthChecks {
+		if h.Failed {
+			return check.StateUnhealthy
+		}
+	}
+
+	return r.Status
+}
+
+// worstStatus returns the worst status of the given health checks and the
+// message of the first of them with that status.
+func worstStatus(healthChecks []HealthCheck) (check.State, string) {
+	status := check.StateHealthy
+	var message string
+	for i, h := range healthChecks {
+		if i == 0 || check.WorseState(status, h.Status) != status {
+			status = h.Status
+			message = h.Message
+		}
+	}
+
+	return status, message
+}
@@ -0,0 +1,183 @@
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	kitendpoint "github.com/go-kit/kit/endpoint"
+	kithttp "github.com/go-kit/kit/transport/http"
+
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/kvm"
+)
+
+const (
+	// Method is the HTTP method this endpoint is registered for.
+	Method = "GET"
+	// Name identifies the endpoint. It is aligned to the package path.
+	Name = "history"
+	// Path is the HTTP request path this endpoint is registered for.
+	Path = "/healthz/history"
+)
+
+// Config represents the configuration used to create a history endpoint.
+type Config struct {
+	// Dependencies.
+	Logger  micrologger.Logger
+	Service *kvm.Service
+}
+
+// DefaultConfig provides a default configuration to create a new history
+// endpoint by best effort.
+func DefaultConfig() Config {
+	return Config{
+		// Dependencies.
+		Logger:  nil,
+		Service: nil,
+	}
+}
+
+// New creates a new configured history endpoint. It exposes the recent
+// results of the checks and the transitions of their states, e.g.
+//
+//	/healthz/history?check=ping,kubelet&since=15m&transitions=1
+//
+// The query parameters since and until take either RFC 3339 timestamps or
+// durations relative to the time of the request. limit returns only the most
+// recent events.
+func New(config Config) (*Endpoint, error) {
+	// Dependencies.
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "logger must not be empty")
+	}
+	if config.Service == nil {
+		return nil, microerror.Maskf(invalidConfigError, "service must not be empty")
+	}
+
+	newEndpoint := &Endpoint{
+		Config: config,
+	}
+
+	return newEndpoint, nil
+}
+
+type Endpoint struct {
+	Config
+}
+
+func (e *Endpoint) Decoder() kithttp.DecodeRequestFunc {
+	return func(ctx context.Context, r *http.Request) (interface{}, error) {
+		filter, err := parseFilter(r.URL.Query(), time.Now())
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		return filter, nil
+	}
+}
+
+func (e *Endpoint) Encoder() kithttp.EncodeResponseFunc {
+	return func(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+		return json.NewEncoder(w).Encode(response)
+	}
+}
+
+func (e *Endpoint) Endpoint() kitendpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		filter, ok := request.(kvm.HistoryFilter)
+		if !ok {
+			return nil, microerror.Maskf(wrongTypeError, "expected '%T' got '%T'", kvm.HistoryFilter{}, request)
+		}
+
+		response := DefaultResponse()
+		response.Events = e.Service.History(filter)
+
+		return response, nil
+	}
+}
+
+func (e *Endpoint) Method() string {
+	return Method
+}
+
+func (e *Endpoint) Middlewares() []kitendpoint.Middleware {
+	return []kitendpoint.Middleware{}
+}
+
+func (e *Endpoint) Name() string {
+	return Name
+}
+
+func (e *Endpoint) Path() string {
+	return Path
+}
+
+// parseFilter parses the query parameters check, since, until, transitions and
+// limit. Relative times are resolved against now.
+func parseFilter(query url.Values, now time.Time) (kvm.HistoryFilter, error) {
+	var filter kvm.HistoryFilter
+	var err error
+
+	for _, value := range query["check"] {
+		for _, c := range strings.Split(value, ",") {
+			c = strings.TrimSpace(c)
+			if c != "" {
+				filter.Checks = append(filter.Checks, c)
+			}
+		}
+	}
+
+	filter.Since, err = parseTime("since", query.Get("since"), now)
+	if err != nil {
+		return kvm.HistoryFilter{}, microerror.Mask(err)
+	}
+	filter.Until, err = parseTime("until", query.Get("until"), now)
+	if err != nil {
+		return kvm.HistoryFilter{}, microerror.Mask(err)
+	}
+
+	switch query.Get("transitions") {
+	case "", "0", "false":
+	case "1", "true":
+		filter.Transitions = true
+	default:
+		return kvm.HistoryFilter{}, microerror.Maskf(invalidRequestError, "transitions must be true or false, got %q", query.Get("transitions"))
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		filter.Limit, err = strconv.Atoi(limit)
+		if err != nil || filter.Limit <= 0 {
+			return kvm.HistoryFilter{}, microerror.Maskf(invalidRequestError, "limit must be a positive integer, got %q", limit)
+		}
+	}
+
+	return filter, nil
+}
+
+// parseTime parses an RFC 3339 timestamp, or a duration going back from now,
+// e.g. 15m.
+func parseTime(name string, value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err == nil {
+		return t, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+
+	return time.Time{}, microerror.Maskf(invalidRequestError, "%s must be an RFC 3339 timestamp or a positive duration, got %q", name, value)
+}
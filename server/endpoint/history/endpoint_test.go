@@ -0,0 +1,92 @@
+package history
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func Test_History_ParseFilter(t *testing.T) {
+	now := time.Date(2020, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		query               string
+		expectedChecks      []string
+		expectedSince       time.Time
+		expectedUntil       time.Time
+		expectedTransitions bool
+		expectedLimit       int
+		expectedErr         func(error) bool
+	}{
+		// test 0 - no filter
+		{
+			query: "",
+		},
+		// test 1 - checks given comma separated and repeated
+		{
+			query:          "check=ping,kubelet&check=api",
+			expectedChecks: []string{"ping", "kubelet", "api"},
+		},
+		// test 2 - relative and absolute times
+		{
+			query:         "since=15m&until=2020-07-01T11:50:00Z",
+			expectedSince: now.Add(-15 * time.Minute),
+			expectedUntil: time.Date(2020, 7, 1, 11, 50, 0, 0, time.UTC),
+		},
+		// test 3 - transitions and limit
+		{
+			query:               "transitions=1&limit=10",
+			expectedTransitions: true,
+			expectedLimit:       10,
+		},
+		// test 4 - invalid time
+		{
+			query:       "since=yesterday",
+			expectedErr: IsInvalidRequest,
+		},
+		// test 5 - invalid limit
+		{
+			query:       "limit=-1",
+			expectedErr: IsInvalidRequest,
+		},
+	}
+
+	for index, test := range tests {
+		query, err := url.ParseQuery(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		filter, err := parseFilter(query, now)
+		if test.expectedErr != nil {
+			if !test.expectedErr(err) {
+				t.Fatalf("%d: expected error got %#v", index, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%d: unexpected error %#v", index, err)
+		}
+
+		if len(filter.Checks) != len(test.expectedChecks) {
+			t.Fatalf("%d: expected checks %v got %v", index, test.expectedChecks, filter.Checks)
+		}
+		for i := range filter.Checks {
+			if filter.Checks[i] != test.expectedChecks[i] {
+				t.Fatalf("%d: expected checks %v got %v", index, test.expectedChecks, filter.Checks)
+			}
+		}
+		if !filter.Since.Equal(test.expectedSince) {
+			t.Fatalf("%d: expected since %s got %s", index, test.expectedSince, filter.Since)
+		}
+		if !filter.Until.Equal(test.expectedUntil) {
+			t.Fatalf("%d: expected until %s got %s", index, test.expectedUntil, filter.Until)
+		}
+		if filter.Transitions != test.expectedTransitions {
+			t.Fatalf("%d: expected transitions %t got %t", index, test.expectedTransitions, filter.Transitions)
+		}
+		if filter.Limit != test.expectedLimit {
+			t.Fatalf("%d: expected limit %d got %d", index, test.expectedLimit, filter.Limit)
+		}
+	}
+}
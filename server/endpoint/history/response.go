@@ -0,0 +1,19 @@
+package history
+
+import (
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/kvm"
+)
+
+// Response is the return value of the history endpoint.
+type Response struct {
+	// Events are the recorded check results matching the request, the oldest
+	// first.
+	Events []kvm.Event `json:"events"`
+}
+
+// DefaultResponse provides a default response object by best effort.
+func DefaultResponse() *Response {
+	return &Response{
+		Events: []kvm.Event{},
+	}
+}
@@ -0,0 +1,114 @@
+package target
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	kitendpoint "github.com/go-kit/kit/endpoint"
+	kithttp "github.com/go-kit/kit/transport/http"
+
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/kvm"
+)
+
+const (
+	// Method is the HTTP method this endpoint is registered for.
+	Method = "GET"
+	// Name identifies the endpoint. It is aligned to the package path.
+	Name = "target"
+	// Path is the HTTP request path this endpoint is registered for.
+	Path = "/target"
+)
+
+// Config represents the configuration used to create a target endpoint.
+type Config struct {
+	// Dependencies.
+	Logger  micrologger.Logger
+	Service *kvm.Service
+}
+
+// DefaultConfig provides a default configuration to create a new target
+// endpoint by best effort.
+func DefaultConfig() Config {
+	return Config{
+		// Dependencies.
+		Logger:  nil,
+		Service: nil,
+	}
+}
+
+// New creates a new configured target endpoint. It exposes the KVM currently
+// probed and when it last changed.
+func New(config Config) (*Endpoint, error) {
+	// Dependencies.
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "logger must not be empty")
+	}
+	if config.Service == nil {
+		return nil, microerror.Maskf(invalidConfigError, "service must not be empty")
+	}
+
+	newEndpoint := &Endpoint{
+		Config: config,
+	}
+
+	return newEndpoint, nil
+}
+
+type Endpoint struct {
+	Config
+}
+
+func (e *Endpoint) Decoder() kithttp.DecodeRequestFunc {
+	return func(ctx context.Context, r *http.Request) (interface{}, error) {
+		return nil, nil
+	}
+}
+
+func (e *Endpoint) Encoder() kithttp.EncodeResponseFunc {
+	return func(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+		return json.NewEncoder(w).Encode(response)
+	}
+}
+
+func (e *Endpoint) Endpoint() kitendpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		target := e.Service.Target()
+
+		response := DefaultResponse()
+		if len(target.IPs) != 0 {
+			response.IPs = target.IPs
+		}
+		if !target.LastChanged.IsZero() {
+			lastChanged := target.LastChanged
+			response.LastChanged = &lastChanged
+		}
+		response.MTU = target.MTU
+		response.Source = target.Source
+		for _, n := range target.Networks {
+			response.Networks = append(response.Networks, n.String())
+		}
+
+		return response, nil
+	}
+}
+
+func (e *Endpoint) Method() string {
+	return Method
+}
+
+func (e *Endpoint) Middlewares() []kitendpoint.Middleware {
+	return []kitendpoint.Middleware{}
+}
+
+func (e *Endpoint) Name() string {
+	return Name
+}
+
+func (e *Endpoint) Path() string {
+	return Path
+}
@@ -0,0 +1,82 @@
+package target
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check"
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/kvm"
+)
+
+func Test_Target_Endpoint(t *testing.T) {
+	tests := []struct {
+		target              kvm.Target
+		expectedIPs         []interface{}
+		expectedLastChanged bool
+	}{
+		// test 0 - no target known yet
+		{
+			target:              kvm.Target{},
+			expectedIPs:         []interface{}{},
+			expectedLastChanged: false,
+		},
+		// test 1 - target known
+		{
+			target: kvm.Target{
+				IPs:    []string{"172.23.3.66"},
+				Source: "/run/flannel/networks/br-1a2b3c.env",
+			},
+			expectedIPs:         []interface{}{"172.23.3.66"},
+			expectedLastChanged: true,
+		},
+	}
+
+	for index, test := range tests {
+		kvmService, err := kvm.New(kvm.Config{
+			Logger:   microloggertest.New(),
+			Registry: check.NewRegistry(),
+
+			Target: test.target,
+		})
+		if err != nil {
+			t.Fatalf("%d: unexpected error %#v", index, err)
+		}
+
+		e, err := New(Config{
+			Logger:  microloggertest.New(),
+			Service: kvmService,
+		})
+		if err != nil {
+			t.Fatalf("%d: unexpected error %#v", index, err)
+		}
+
+		response, err := e.Endpoint()(context.Background(), nil)
+		if err != nil {
+			t.Fatalf("%d: unexpected error %#v", index, err)
+		}
+
+		w := httptest.NewRecorder()
+		err = e.Encoder()(context.Background(), w, response)
+		if err != nil {
+			t.Fatalf("%d: unexpected error %#v", index, err)
+		}
+
+		var body map[string]interface{}
+		err = json.Unmarshal(w.Body.Bytes(), &body)
+		if err != nil {
+			t.Fatalf("%d: unexpected error %#v", index, err)
+		}
+		if !reflect.DeepEqual(body["ips"], test.expectedIPs) {
+			t.Fatalf("%d: expected IPs %v got %v", index, test.expectedIPs, body["ips"])
+		}
+		_, ok := body["last_changed"]
+		if ok != test.expectedLastChanged {
+			t.Fatalf("%d: expected last changed %t got %v", index, test.expectedLastChanged, body["last_changed"])
+		}
+	}
+}
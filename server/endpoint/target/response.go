@@ -0,0 +1,25 @@
+package target
+
+import "time"
+
+// Response is the return value of the target endpoint.
+type Response struct {
+	IPs []string `json:"ips"`
+	// LastChanged is the time the target was last changed. It is omitted as
+	// long as no target is known.
+	LastChanged *time.Time `json:"last_changed,omitempty"`
+	MTU         int        `json:"mtu,omitempty"`
+	Networks    []string   `json:"networks,omitempty"`
+	Source      string     `json:"source,omitempty"`
+}
+
+// DefaultResponse provides a default response object by best effort.
+func DefaultResponse() *Response {
+	return &Response{
+		IPs:         []string{},
+		LastChanged: nil,
+		MTU:         0,
+		Networks:    nil,
+		Source:      "",
+	}
+}
@@ -2,7 +2,10 @@ package server
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
 	"sync"
 
 	"github.com/giantswarm/microerror"
@@ -11,6 +14,7 @@ import (
 	kithttp "github.com/go-kit/kit/transport/http"
 
 	"github.com/giantswarm/k8s-kvm-health/server/endpoint"
+	"github.com/giantswarm/k8s-kvm-health/server/endpoint/history"
 	"github.com/giantswarm/k8s-kvm-health/server/middleware"
 	"github.com/giantswarm/k8s-kvm-health/service"
 )
@@ -63,19 +67,30 @@ func New(config Config) (microserver.Server, error) {
 		}
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+
 	newServer := &server{
 		// Dependencies.
-		logger: config.MicroServerConfig.Logger,
+		logger:  config.MicroServerConfig.Logger,
+		service: config.Service,
 
 		// Internals.
 		bootOnce:     sync.Once{},
+		cancel:       cancel,
 		config:       config.MicroServerConfig,
+		ctx:          ctx,
 		shutdownOnce: sync.Once{},
 	}
 
 	// Apply internals to the micro server config.
 	newServer.config.Endpoints = []microserver.Endpoint{
 		endpointCollection.Healthz,
+		endpointCollection.History,
+		endpointCollection.Config,
+		endpointCollection.Livez,
+		endpointCollection.Readyz,
+		endpointCollection.Startupz,
+		endpointCollection.Target,
 		endpointCollection.Version,
 	}
 	newServer.config.ErrorEncoder = newServer.newErrorEncoder()
@@ -85,18 +100,29 @@ func New(config Config) (microserver.Server, error) {
 
 type server struct {
 	// Dependencies.
-	logger micrologger.Logger
+	logger  micrologger.Logger
+	service *service.Service
 
 	// Internals.
 	bootOnce     sync.Once
+	cancel       context.CancelFunc
 	config       microserver.Config
+	ctx          context.Context
 	shutdownOnce sync.Once
 }
 
 func (s *server) Boot() {
 	s.bootOnce.Do(func() {
-		// Here goes your custom boot logic for your server/endpoint/middleware, if
-		// any.
+		// Restore the health state persisted before a restart, before any
+		// check is performed. Without it the service starts from scratch.
+		err := s.service.LoadState()
+		if err != nil {
+			s.logger.Log("level", "error", "message", "failed to load health state", "stack", fmt.Sprintf("%#v", err)) // nolint
+		}
+
+		// Start the background work of the service, which runs until the server
+		// shuts down.
+		s.service.Boot(s.ctx)
 	})
 }
 
@@ -106,14 +132,51 @@ func (s *server) Config() microserver.Config {
 
 func (s *server) Shutdown() {
 	s.shutdownOnce.Do(func() {
-		// Here goes your custom shutdown logic for your server/endpoint/middleware,
-		// if any.
+		// Stop the background work of the service.
+		s.cancel()
+
+		// Persist the latest health state for the next start.
+		err := s.service.SaveState()
+		if err != nil {
+			s.logger.Log("level", "error", "message", "failed to save health state", "stack", fmt.Sprintf("%#v", err)) // nolint
+		}
 	})
 }
 
+// ShutdownOnSignal shuts down the given server once one of the given signals
+// is received. microkit only shuts down the server it creates from the config
+// of the given one and exits right after, so the custom shutdown logic of our
+// server has to be triggered here. It races the exit of microkit, which is
+// why changes of the health state are saved right away and the state saved
+// on shutdown only updates the streaks of the checks. The returned channel
+// is closed once the server is shut down.
+func ShutdownOnSignal(s microserver.Server, signals ...os.Signal) <-chan struct{} {
+	listener := make(chan os.Signal, 1)
+	signal.Notify(listener, signals...)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		<-listener
+		signal.Stop(listener)
+
+		s.Shutdown()
+	}()
+
+	return done
+}
+
 func (s *server) newErrorEncoder() kithttp.ErrorEncoder {
 	return func(ctx context.Context, err error, w http.ResponseWriter) {
 		rErr := err.(microserver.ResponseError)
+		if history.IsInvalidRequest(rErr.Underlying()) {
+			rErr.SetCode(microserver.CodeInvalidInput)
+			rErr.SetMessage(rErr.Underlying().Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
 		rErr.SetCode(microserver.CodeInternalError)
 		rErr.SetMessage("An unexpected error occurred. Sorry for the inconvenience.")
 		w.WriteHeader(http.StatusInternalServerError)
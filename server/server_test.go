@@ -0,0 +1,273 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/microkit/command/daemon"
+	microserver "github.com/giantswarm/microkit/server"
+	"github.com/giantswarm/micrologger/microloggertest"
+	"github.com/spf13/viper"
+
+	"github.com/giantswarm/k8s-kvm-health/flag"
+	"github.com/giantswarm/k8s-kvm-health/service"
+	"github.com/giantswarm/k8s-kvm-health/service/healthz"
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check"
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/persist"
+)
+
+const (
+	// daemonDirEnv makes the test binary run the daemon instead of the tests,
+	// with the state file in the given directory.
+	daemonDirEnv = "K8S_KVM_HEALTH_TEST_DAEMON_DIR"
+	// daemonKubeletPortEnv is the port of the kubelet the daemon checks.
+	daemonKubeletPortEnv = "K8S_KVM_HEALTH_TEST_DAEMON_KUBELET_PORT"
+)
+
+func Test_Server_ShutdownOnSignal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "k8s-kvm-health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	stateFile := filepath.Join(dir, "state.json")
+
+	var newService *service.Service
+	{
+		// the flannel file never shows up, waiting for it only stops on shutdown
+		f := &flag.Flag{}
+		f.Service.FlannelFile = filepath.Join(dir, "br-1a2b3c.env")
+		f.Service.StateFile = stateFile
+
+		config := service.DefaultConfig()
+		config.Flag = f
+		config.Logger = microloggertest.New()
+
+		config.Description = "test"
+		config.GitCommit = "test"
+		config.Name = "test"
+		config.Source = "test"
+
+		newService, err = service.New(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var newServer microserver.Server
+	{
+		config := DefaultConfig()
+		config.MicroServerConfig.Logger = microloggertest.New()
+		config.Service = newService
+
+		newServer, err = New(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	newServer.Boot()
+	done := ShutdownOnSignal(newServer, syscall.SIGTERM)
+
+	err = syscall.Kill(os.Getpid(), syscall.SIGTERM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected server to shut down on SIGTERM")
+	}
+
+	b, err := ioutil.ReadFile(stateFile)
+	if err != nil {
+		t.Fatalf("expected state to be saved on shutdown, %s", err)
+	}
+
+	var state persist.State
+	err = json.Unmarshal(b, &state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Version != persist.Version {
+		t.Fatalf("expected version %d got %d", persist.Version, state.Version)
+	}
+	if state.SavedAt.IsZero() {
+		t.Fatalf("expected saved_at to be set")
+	}
+}
+
+// Test_Server_ShutdownOnSignal_Daemon runs the microkit daemon in a separate
+// process, which microkit exits on SIGTERM regardless of our shutdown, and
+// ensures the health state survives it.
+func Test_Server_ShutdownOnSignal_Daemon(t *testing.T) {
+	if dir := os.Getenv(daemonDirEnv); dir != "" {
+		runDaemon(t, dir, os.Getenv(daemonKubeletPortEnv))
+		return
+	}
+
+	dir, err := ioutil.TempDir("", "k8s-kvm-health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	stateFile := filepath.Join(dir, "state.json")
+
+	kubelet := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok") // nolint
+	}))
+	defer kubelet.Close()
+
+	kubeletURL, err := url.Parse(kubelet.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^Test_Server_ShutdownOnSignal_Daemon$") // nolint:gosec
+	cmd.Env = append(os.Environ(), daemonDirEnv+"="+dir, daemonKubeletPortEnv+"="+kubeletURL.Port())
+	// microkit itself races between booting and shutting down its server,
+	// which must not fail the exit status when testing with -race
+	cmd.Env = append(cmd.Env, "GORACE=exitcode=0")
+	err = cmd.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Process.Kill() // nolint
+
+	// the state is saved once the kubelet check became healthy, long before
+	// the save interval
+	deadline := time.Now().Add(10 * time.Second)
+	for !kubeletHealthy(stateFile) {
+		if time.Now().After(deadline) {
+			t.Fatal("expected healthy kubelet check to be saved")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	err = cmd.Process.Signal(syscall.SIGTERM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-exited:
+		if err != nil {
+			t.Fatalf("expected daemon to exit successfully, %s", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("expected daemon to exit on SIGTERM")
+	}
+
+	if !kubeletHealthy(stateFile) {
+		t.Fatal("expected healthy kubelet check to be saved after exit")
+	}
+}
+
+// runDaemon runs the microkit daemon like main does, checking only the
+// kubelet at the given port of 127.0.0.1. It does not return, since microkit
+// exits the process on SIGTERM.
+func runDaemon(t *testing.T, dir string, kubeletPort string) {
+	flannelFile := filepath.Join(dir, "br-1a2b3c.env")
+	err := ioutil.WriteFile(flannelFile, []byte("FLANNEL_NETWORK=127.0.0.0/8\nFLANNEL_SUBNET=127.0.0.0/30\nFLANNEL_MTU=1450\n"), 0644) // nolint:gosec
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &flag.Flag{}
+	f.Service.AddressFixedIPs = "127.0.0.1"
+	f.Service.AddressStrategy = "fixed"
+	f.Service.CheckInterval = "100ms"
+	f.Service.Checks = healthz.CheckKubelet
+	f.Service.FlannelFile = flannelFile
+	f.Service.KubeletPort = kubeletPort
+	f.Service.StateFile = filepath.Join(dir, "state.json")
+	f.Service.StateSaveInterval = "1h"
+
+	serverFactory := func(v *viper.Viper) microserver.Server {
+		serviceConfig := service.DefaultConfig()
+		serviceConfig.Flag = f
+		serviceConfig.Logger = microloggertest.New()
+
+		serviceConfig.Description = "test"
+		serviceConfig.GitCommit = "test"
+		serviceConfig.Name = "test"
+		serviceConfig.Source = "test"
+
+		newService, err := service.New(serviceConfig)
+		if err != nil {
+			panic(err)
+		}
+
+		serverConfig := DefaultConfig()
+		serverConfig.MicroServerConfig.Logger = microloggertest.New()
+		serverConfig.MicroServerConfig.ListenAddress = "http://127.0.0.1:0"
+		serverConfig.MicroServerConfig.Viper = v
+		serverConfig.Service = newService
+
+		newServer, err := New(serverConfig)
+		if err != nil {
+			panic(err)
+		}
+
+		newServer.Boot()
+		ShutdownOnSignal(newServer, syscall.SIGINT, syscall.SIGTERM)
+
+		return newServer
+	}
+
+	newCommand, err := daemon.New(daemon.Config{
+		Logger:        microloggertest.New(),
+		ServerFactory: serverFactory,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cobraCommand := newCommand.CobraCommand()
+	cobraCommand.SetArgs([]string{})
+	err = cobraCommand.Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// kubeletHealthy returns whether the given state file holds a healthy kubelet
+// check.
+func kubeletHealthy(stateFile string) bool {
+	b, err := ioutil.ReadFile(stateFile)
+	if err != nil {
+		return false
+	}
+
+	var state persist.State
+	err = json.Unmarshal(b, &state)
+	if err != nil {
+		return false
+	}
+
+	for _, cs := range state.Checks {
+		if cs.Check == healthz.CheckKubelet && cs.State == check.StateHealthy {
+			return true
+		}
+	}
+
+	return false
+}
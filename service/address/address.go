@@ -0,0 +1,129 @@
+// Package address implements the IP arithmetic used to derive the address of
+// the KVM guest from the flannel subnet of its host. It works for IPv4 and
+// IPv6 alike.
+package address
+
+import (
+	"math/big"
+	"net"
+
+	"github.com/giantswarm/microerror"
+)
+
+// Family is the address family of an IP.
+type Family string
+
+const (
+	IPv4 Family = "ipv4"
+	IPv6 Family = "ipv6"
+)
+
+// FamilyOf returns the address family of the given IP. It returns an empty
+// family for invalid IPs.
+func FamilyOf(ip net.IP) Family {
+	if ip.To4() != nil {
+		return IPv4
+	}
+	if ip.To16() != nil {
+		return IPv6
+	}
+
+	return ""
+}
+
+// Add returns the IP n addresses after ip. n may be negative. The result is of
+// the same family as ip. An outOfRangeError is returned in case the result
+// overflows the address space of the family.
+func Add(ip net.IP, n int64) (net.IP, error) {
+	b := normalize(ip)
+	if b == nil {
+		return nil, microerror.Maskf(invalidIPError, "%q", ip)
+	}
+
+	v := new(big.Int).SetBytes(b)
+	v.Add(v, big.NewInt(n))
+	if v.Sign() < 0 || v.BitLen() > len(b)*8 {
+		return nil, microerror.Maskf(outOfRangeError, "%s%+d exceeds the %s address space", ip, n, FamilyOf(ip))
+	}
+
+	vb := v.Bytes()
+	result := make(net.IP, len(b))
+	copy(result[len(result)-len(vb):], vb)
+
+	return result, nil
+}
+
+// AddInSubnet works like Add but additionally ensures that the result is a
+// usable host address of the given subnet. That is, it must be part of the
+// subnet and must neither be the network address nor, for IPv4, the broadcast
+// address of the subnet.
+func AddInSubnet(ip net.IP, n int64, subnet *net.IPNet) (net.IP, error) {
+	result, err := Add(ip, n)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	err = ValidateHost(result, subnet)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return result, nil
+}
+
+// ValidateHost returns an outOfRangeError in case ip is not a usable host
+// address of subnet.
+func ValidateHost(ip net.IP, subnet *net.IPNet) error {
+	if !subnet.Contains(ip) {
+		return microerror.Maskf(outOfRangeError, "%s is not part of subnet %s", ip, subnet)
+	}
+
+	ones, bits := subnet.Mask.Size()
+	if bits-ones < 2 {
+		// Point-to-point and single host subnets do not reserve any address.
+		return nil
+	}
+
+	if ip.Equal(subnet.IP) {
+		return microerror.Maskf(outOfRangeError, "%s is the network address of subnet %s", ip, subnet)
+	}
+	if FamilyOf(ip) == IPv4 && ip.Equal(Broadcast(subnet)) {
+		return microerror.Maskf(outOfRangeError, "%s is the broadcast address of subnet %s", ip, subnet)
+	}
+
+	return nil
+}
+
+// Broadcast returns the last address of the given subnet.
+func Broadcast(subnet *net.IPNet) net.IP {
+	b := normalize(subnet.IP)
+	mask := subnet.Mask
+	if len(mask) != len(b) {
+		return nil
+	}
+
+	result := make(net.IP, len(b))
+	for i := range b {
+		result[i] = b[i] | ^mask[i]
+	}
+
+	return result
+}
+
+// normalize returns a copy of ip in its 4 byte representation for IPv4 and
+// its 16 byte representation for IPv6.
+func normalize(ip net.IP) net.IP {
+	var b net.IP
+	if v4 := ip.To4(); v4 != nil {
+		b = v4
+	} else if v6 := ip.To16(); v6 != nil {
+		b = v6
+	} else {
+		return nil
+	}
+
+	result := make(net.IP, len(b))
+	copy(result, b)
+
+	return result
+}
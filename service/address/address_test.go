@@ -0,0 +1,143 @@
+package address
+
+import (
+	"net"
+	"testing"
+)
+
+func Test_Address_Add(t *testing.T) {
+	tests := []struct {
+		ip          string
+		n           int64
+		expectedIP  string
+		expectedErr func(error) bool
+	}{
+		// test 0
+		{
+			ip:         "172.23.3.65",
+			n:          1,
+			expectedIP: "172.23.3.66",
+		},
+		// test 1 - carry into the next octet
+		{
+			ip:         "10.0.0.255",
+			n:          1,
+			expectedIP: "10.0.1.0",
+		},
+		// test 2 - negative offset
+		{
+			ip:         "10.0.1.0",
+			n:          -1,
+			expectedIP: "10.0.0.255",
+		},
+		// test 3 - overflow of the IPv4 address space
+		{
+			ip:          "255.255.255.255",
+			n:           1,
+			expectedErr: IsOutOfRange,
+		},
+		// test 4
+		{
+			ip:         "fd00:10:244:1::1",
+			n:          1,
+			expectedIP: "fd00:10:244:1::2",
+		},
+		// test 5 - carry into the next group
+		{
+			ip:         "fd00::ffff",
+			n:          1,
+			expectedIP: "fd00::1:0",
+		},
+		// test 6 - overflow of the IPv6 address space
+		{
+			ip:          "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff",
+			n:           1,
+			expectedErr: IsOutOfRange,
+		},
+	}
+
+	for index, test := range tests {
+		ip, err := Add(net.ParseIP(test.ip), test.n)
+
+		if test.expectedErr != nil {
+			if !test.expectedErr(err) {
+				t.Fatalf("%d: unexpected error %#v", index, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%d: unexpected error %#v", index, err)
+		}
+		if ip.String() != test.expectedIP {
+			t.Fatalf("%d: expected ip %s but got %s", index, test.expectedIP, ip)
+		}
+	}
+}
+
+func Test_Address_AddInSubnet(t *testing.T) {
+	tests := []struct {
+		ip          string
+		n           int64
+		subnet      string
+		expectedIP  string
+		expectedErr func(error) bool
+	}{
+		// test 0
+		{
+			ip:         "172.23.3.65",
+			n:          1,
+			subnet:     "172.23.3.64/30",
+			expectedIP: "172.23.3.66",
+		},
+		// test 1 - broadcast address
+		{
+			ip:          "172.23.3.66",
+			n:           1,
+			subnet:      "172.23.3.64/30",
+			expectedErr: IsOutOfRange,
+		},
+		// test 2 - bridge ip ending in .255 must not wrap into the next subnet
+		{
+			ip:          "10.0.0.255",
+			n:           1,
+			subnet:      "10.0.0.0/24",
+			expectedErr: IsOutOfRange,
+		},
+		// test 3
+		{
+			ip:         "fd00:10:244:1::1",
+			n:          1,
+			subnet:     "fd00:10:244:1::/64",
+			expectedIP: "fd00:10:244:1::2",
+		},
+		// test 4 - outside of IPv6 subnet
+		{
+			ip:          "fd00:10:244:1:ffff:ffff:ffff:ffff",
+			n:           1,
+			subnet:      "fd00:10:244:1::/64",
+			expectedErr: IsOutOfRange,
+		},
+	}
+
+	for index, test := range tests {
+		_, subnet, err := net.ParseCIDR(test.subnet)
+		if err != nil {
+			t.Fatalf("%d: unexpected error %#v", index, err)
+		}
+
+		ip, err := AddInSubnet(net.ParseIP(test.ip), test.n, subnet)
+
+		if test.expectedErr != nil {
+			if !test.expectedErr(err) {
+				t.Fatalf("%d: unexpected error %#v", index, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%d: unexpected error %#v", index, err)
+		}
+		if ip.String() != test.expectedIP {
+			t.Fatalf("%d: expected ip %s but got %s", index, test.expectedIP, ip)
+		}
+	}
+}
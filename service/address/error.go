@@ -0,0 +1,31 @@
+package address
+
+import "github.com/giantswarm/microerror"
+
+var invalidIPError = microerror.New("invalid ip")
+
+// IsInvalidIP asserts invalidIPError.
+func IsInvalidIP(err error) bool {
+	return microerror.Cause(err) == invalidIPError
+}
+
+var outOfRangeError = microerror.New("out of range")
+
+// IsOutOfRange asserts outOfRangeError.
+func IsOutOfRange(err error) bool {
+	return microerror.Cause(err) == outOfRangeError
+}
+
+var invalidConfigError = microerror.New("invalid config")
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
+var notFoundError = microerror.New("not found")
+
+// IsNotFound asserts notFoundError.
+func IsNotFound(err error) bool {
+	return microerror.Cause(err) == notFoundError
+}
@@ -0,0 +1,68 @@
+package address
+
+import (
+	"net"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+)
+
+// parseLeases returns the IPs of all leases in the given DHCP lease file in
+// the order they appear. Two formats are understood.
+//
+// dnsmasq writes one lease per line.
+//
+//	1610000000 52:54:00:12:34:56 172.23.3.66 guest *
+//
+// ISC dhcpd writes one block per lease.
+//
+//	lease 172.23.3.66 {
+//	  hardware ethernet 52:54:00:12:34:56;
+//	}
+//
+// Lines not matching either format are ignored.
+func parseLeases(b []byte) []net.IP {
+	var ips []net.IP
+
+	for _, line := range strings.Split(string(b), "\n") {
+		fields := strings.Fields(line)
+
+		var candidate string
+		switch {
+		case len(fields) >= 3 && fields[0] == "lease":
+			candidate = fields[1]
+		case len(fields) >= 4 && fields[0] != "duid":
+			candidate = fields[2]
+		default:
+			continue
+		}
+
+		ip := net.ParseIP(candidate)
+		if ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+
+	return ips
+}
+
+// parseStaticFile returns the IPs of the given file, which contains one IP
+// per line. Blank lines and lines starting with # are ignored.
+func parseStaticFile(b []byte) ([]net.IP, error) {
+	var ips []net.IP
+
+	for i, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		ip := net.ParseIP(line)
+		if ip == nil {
+			return nil, microerror.Maskf(invalidIPError, "line %d: %q", i+1, line)
+		}
+		ips = append(ips, ip)
+	}
+
+	return ips, nil
+}
@@ -0,0 +1,174 @@
+package address
+
+import (
+	"io/ioutil"
+	"net"
+
+	"github.com/giantswarm/microerror"
+)
+
+// Strategy defines how the address of the KVM guest is derived from the
+// flannel subnet of its host.
+type Strategy string
+
+const (
+	// StrategyFlannelIPOffset adds Offset to the IP of the flannel subnet,
+	// which is the IP of the bridge. This is the default strategy with a
+	// default offset of 1.
+	StrategyFlannelIPOffset Strategy = "flannel-ip-offset"
+	// StrategySubnetOffset adds Offset to the network address of the flannel
+	// subnet. The default offset is 2.
+	StrategySubnetOffset Strategy = "subnet-offset"
+	// StrategyFixed uses the configured FixedIPs as they are.
+	StrategyFixed Strategy = "fixed"
+	// StrategyDHCPLease reads the address from the DHCP lease file File. Both
+	// dnsmasq and ISC dhcpd lease files are supported. The last lease within
+	// the flannel subnet is used.
+	StrategyDHCPLease Strategy = "dhcp-lease"
+	// StrategyStaticFile reads the address from the file File, which contains
+	// one IP per line, e.g. as written by kvm-operator. The first IP within the
+	// flannel subnet is used.
+	StrategyStaticFile Strategy = "static-file"
+)
+
+const (
+	defaultFlannelIPOffset = 1
+	defaultSubnetOffset    = 2
+)
+
+// Strategies lists all the supported strategies.
+var Strategies = []Strategy{
+	StrategyFlannelIPOffset,
+	StrategySubnetOffset,
+	StrategyFixed,
+	StrategyDHCPLease,
+	StrategyStaticFile,
+}
+
+// Config configures the derivation of the KVM guest address. The zero value
+// is valid and derives the address directly after the bridge IP.
+type Config struct {
+	// Strategy is the strategy used. It defaults to StrategyFlannelIPOffset.
+	Strategy Strategy
+	// Offset is used by the offset strategies. Zero means the default offset
+	// of the strategy.
+	Offset int64
+	// FixedIPs are used by StrategyFixed. There is at most one IP per address
+	// family.
+	FixedIPs []net.IP
+	// File is the file read by StrategyDHCPLease and StrategyStaticFile.
+	File string
+}
+
+// Validate returns an invalidConfigError in case the config is not usable.
+func (c Config) Validate() error {
+	switch c.strategy() {
+	case StrategyFlannelIPOffset, StrategySubnetOffset:
+		if len(c.FixedIPs) != 0 || c.File != "" {
+			return microerror.Maskf(invalidConfigError, "strategy %q must not be used with fixed ips or a file", c.strategy())
+		}
+	case StrategyFixed:
+		if len(c.FixedIPs) == 0 {
+			return microerror.Maskf(invalidConfigError, "strategy %q requires fixed ips", c.strategy())
+		}
+		families := map[Family]bool{}
+		for _, ip := range c.FixedIPs {
+			family := FamilyOf(ip)
+			if family == "" {
+				return microerror.Maskf(invalidConfigError, "fixed ip %q is invalid", ip)
+			}
+			if families[family] {
+				return microerror.Maskf(invalidConfigError, "at most one fixed %s address may be given", family)
+			}
+			families[family] = true
+		}
+	case StrategyDHCPLease, StrategyStaticFile:
+		if c.File == "" {
+			return microerror.Maskf(invalidConfigError, "strategy %q requires a file", c.strategy())
+		}
+	default:
+		return microerror.Maskf(invalidConfigError, "unknown strategy %q, must be one of %v", c.Strategy, Strategies)
+	}
+
+	if c.Offset != 0 && !c.isOffsetStrategy() {
+		return microerror.Maskf(invalidConfigError, "strategy %q must not be used with an offset", c.strategy())
+	}
+
+	return nil
+}
+
+// Derive returns the address of the KVM guest for the address family of the
+// given flannel subnet. bridgeIP is the IP of the flannel subnet, e.g.
+// 172.23.3.65 for FLANNEL_SUBNET=172.23.3.65/30. Addresses derived by offset
+// or read from a file must be usable host addresses of the subnet.
+func (c Config) Derive(bridgeIP net.IP, subnet *net.IPNet) (net.IP, error) {
+	family := FamilyOf(bridgeIP)
+
+	switch c.strategy() {
+	case StrategyFlannelIPOffset:
+		return AddInSubnet(bridgeIP, c.offset(defaultFlannelIPOffset), subnet)
+	case StrategySubnetOffset:
+		return AddInSubnet(subnet.IP, c.offset(defaultSubnetOffset), subnet)
+	case StrategyFixed:
+		for _, ip := range c.FixedIPs {
+			if FamilyOf(ip) == family {
+				return normalize(ip), nil
+			}
+		}
+		return nil, microerror.Maskf(notFoundError, "no fixed %s address configured", family)
+	case StrategyDHCPLease, StrategyStaticFile:
+		b, err := ioutil.ReadFile(c.File)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		var ips []net.IP
+		if c.strategy() == StrategyDHCPLease {
+			ips = parseLeases(b)
+			// The most recent lease is appended last.
+			for i, j := 0, len(ips)-1; i < j; i, j = i+1, j-1 {
+				ips[i], ips[j] = ips[j], ips[i]
+			}
+		} else {
+			ips, err = parseStaticFile(b)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+		}
+
+		for _, ip := range ips {
+			if subnet.Contains(ip) && ValidateHost(ip, subnet) == nil {
+				return normalize(ip), nil
+			}
+		}
+		return nil, microerror.Maskf(notFoundError, "no address of subnet %s found in %s", subnet, c.File)
+	}
+
+	return nil, microerror.Maskf(invalidConfigError, "unknown strategy %q", c.Strategy)
+}
+
+// IsFixed returns true in case the address is not derived from the flannel
+// subnet but configured explicitly.
+func (c Config) IsFixed() bool {
+	return c.strategy() == StrategyFixed
+}
+
+func (c Config) isOffsetStrategy() bool {
+	return c.strategy() == StrategyFlannelIPOffset || c.strategy() == StrategySubnetOffset
+}
+
+func (c Config) offset(defaultOffset int64) int64 {
+	if c.Offset == 0 {
+		return defaultOffset
+	}
+
+	return c.Offset
+}
+
+func (c Config) strategy() Strategy {
+	if c.Strategy == "" {
+		return StrategyFlannelIPOffset
+	}
+
+	return c.Strategy
+}
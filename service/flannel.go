@@ -1,36 +1,32 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net"
-	"os"
-	"regexp"
+	"strconv"
 	"strings"
-	"time"
 
 	"github.com/giantswarm/microerror"
-	"github.com/giantswarm/micrologger"
+
+	"github.com/giantswarm/k8s-kvm-health/service/address"
+	"github.com/giantswarm/k8s-kvm-health/service/flannel"
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/kvm"
 )
 
 const (
-	MaxRetry = 100
+	ipFamilyDual = "dual"
+	ipFamilyIPv4 = string(address.IPv4)
+	ipFamilyIPv6 = string(address.IPv6)
 )
 
-func (c *Config) LoadFlannelConfig() error {
-	err := c.waitForFlannelFile(c.Logger)
-	if err != nil {
-		return microerror.Mask(err)
-	}
-
-	// fetch configuration from OS env
-	confFile, err := c.readFlannelFile()
-	if err != nil {
-		return microerror.Mask(err)
-	}
-
-	// parse config and generate IP for interfaces
-	err = c.parseIPs(confFile)
+// LoadFlannelConfig waits for the flannel file and generates the kvm ips from
+// it. Waiting is cancelled when ctx is done. onWaiting is called whenever the
+// reason for waiting changes.
+func (c *Config) LoadFlannelConfig(ctx context.Context, onWaiting func(reason string)) error {
+	// wait for the file and parse config and generate IP for interfaces
+	err := c.waitForFlannelFile(ctx, onWaiting)
 	if err != nil {
 		return microerror.Mask(err)
 	}
@@ -49,47 +45,206 @@ func (c *Config) readFlannelFile() ([]byte, error) {
 	return fileContent, nil
 }
 
-// parseIPs parses kvm configuration file and generate ips for interface
+// parseIPs parses kvm configuration file and generate ips for interface. The
+// kvm ip of each address family is derived from the flannel subnet of that
+// family using the configured address strategy, which by default picks the ip
+// directly after the bridge ip. At least one of FLANNEL_SUBNET and
+// FLANNEL_IPV6_SUBNET must be given unless the kvm ips are fixed.
 func (c *Config) parseIPs(confFile []byte) error {
-	// get FLANNEL_SUBNET from kvm file via regexp
-	r, _ := regexp.Compile("FLANNEL_SUBNET=[0-9]+.[0-9]+.[0-9]+.[0-9]+/[0-9]+")
-	flannelLine := r.Find(confFile)
-	// check if regexp returned non-empty line
-	if len(flannelLine) < 5 {
-		return microerror.Mask(invalidKVMConfigurationError)
+	env, err := parseFlannelEnv(confFile)
+	if err != nil {
+		return microerror.Mask(err)
 	}
 
-	// parse kvm subnet
-	flannelSubnetStr := strings.Split(string(flannelLine), "=")[1]
-	flannelIP, _, err := net.ParseCIDR(flannelSubnetStr)
+	ipv4, ipv6, err := c.deriveKVMIPs(env)
 	if err != nil {
-		return microerror.Maskf(failedParsingFlannelSubnetError, "%v", err)
+		return microerror.Mask(err)
 	}
-	// force ipv4 for later trick
-	flannelIP = flannelIP.To4()
 
-	// get kvm ip, which is just one number bigger than bridge hence the [3]++ trick
-	flannelIP[3]++
-	c.Flag.Service.IPAddress = flannelIP.String()
+	c.Flag.Service.IPAddress = ipv4
+	c.Flag.Service.IPv6Address = ipv6
+	c.flannelEnv = env
 
 	return nil
 }
 
-// waitForFlannelFile waits until flannel file is created
-func (c *Config) waitForFlannelFile(newLogger micrologger.Logger) error {
-	// wait for file creation
-	for count := 0; ; count++ {
-		// don't wait forever, if file is not created within retry limit, exit with failure
-		if count > MaxRetry {
-			return microerror.Maskf(invalidFlannelFileError, "After 100sec flannel file is not created. Exiting")
+// parseFlannelEnv parses the content of the flannel file.
+func parseFlannelEnv(confFile []byte) (flannel.Env, error) {
+	env, err := flannel.Parse(confFile)
+	if err != nil {
+		return flannel.Env{}, microerror.Maskf(invalidKVMConfigurationError, "%s", err)
+	}
+
+	return env, nil
+}
+
+// deriveKVMIPs returns the kvm ip of each address family for the given
+// flannel configuration. The ip of a family not configured is empty.
+func (c *Config) deriveKVMIPs(env flannel.Env) (string, string, error) {
+	var err error
+
+	var ipv4, ipv6 string
+	if c.addressConfig.IsFixed() {
+		for _, ip := range c.addressConfig.FixedIPs {
+			if address.FamilyOf(ip) == address.IPv4 {
+				ipv4 = ip.String()
+			} else {
+				ipv6 = ip.String()
+			}
 		}
-		// check if file exists
-		if _, err := os.Stat(c.Flag.Service.FlannelFile); !os.IsNotExist(err) {
-			break
+	} else {
+		if env.Subnet.IsEmpty() && env.IPv6Subnet.IsEmpty() {
+			return "", "", microerror.Maskf(invalidKVMConfigurationError, "%s or %s must not be empty", flannel.KeySubnet, flannel.KeyIPv6Subnet)
+		}
+
+		ipv4, err = c.deriveKVMIP(env.Subnet, env.Network)
+		if err != nil {
+			return "", "", microerror.Mask(err)
+		}
+		ipv6, err = c.deriveKVMIP(env.IPv6Subnet, env.IPv6Network)
+		if err != nil {
+			return "", "", microerror.Mask(err)
 		}
-		_ = newLogger.Log("debug", fmt.Sprintf("Waiting for file '%s' to be created.", c.Flag.Service.FlannelFile))
-		time.Sleep(1 * time.Second)
 	}
-	// all good
-	return nil
+
+	return ipv4, ipv6, nil
+}
+
+// deriveKVMIP returns the kvm ip for the given flannel subnet, or an empty
+// string in case the subnet is not set.
+func (c *Config) deriveKVMIP(subnet flannel.CIDR, network flannel.CIDR) (string, error) {
+	if subnet.IsEmpty() {
+		return "", nil
+	}
+
+	kvmIP, err := c.addressConfig.Derive(subnet.IP, subnet.Net)
+	if address.IsOutOfRange(err) {
+		return "", microerror.Maskf(failedParsingFlannelSubnetError, "%s", err)
+	} else if err != nil {
+		return "", microerror.Maskf(invalidKVMConfigurationError, "%s", err)
+	}
+
+	// the kvm ip has to be part of the flannel network, otherwise the guest is
+	// not reachable through the bridge
+	if !network.IsEmpty() && !network.Net.Contains(kvmIP) {
+		return "", microerror.Maskf(invalidKVMConfigurationError, "kvm ip %s is not part of flannel network %s", kvmIP, network.Net)
+	}
+
+	return kvmIP.String(), nil
+}
+
+// newAddressConfig creates the configuration of the kvm ip derivation from
+// the flags.
+func (c *Config) newAddressConfig() (address.Config, error) {
+	f := c.Flag.Service
+
+	addressConfig := address.Config{
+		Strategy: address.Strategy(f.AddressStrategy),
+		File:     f.AddressFile,
+	}
+
+	if f.AddressOffset != "" {
+		offset, err := strconv.ParseInt(f.AddressOffset, 10, 64)
+		if err != nil {
+			return address.Config{}, microerror.Maskf(invalidConfigError, "address offset must be an integer, got %q", f.AddressOffset)
+		}
+		addressConfig.Offset = offset
+	}
+
+	for _, s := range strings.Split(f.AddressFixedIPs, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return address.Config{}, microerror.Maskf(invalidConfigError, "fixed address %q must be a valid IP", s)
+		}
+		addressConfig.FixedIPs = append(addressConfig.FixedIPs, ip)
+	}
+
+	err := addressConfig.Validate()
+	if err != nil {
+		return address.Config{}, microerror.Maskf(invalidConfigError, "%s", err)
+	}
+
+	return addressConfig, nil
+}
+
+// kvmTarget returns the kvm target of the address families selected by
+// Flag.Service.IPFamily. By default all families configured in the flannel
+// file are probed.
+func (c *Config) kvmTarget(env flannel.Env, ipv4, ipv6 string) (kvm.Target, error) {
+	var ips []string
+	switch c.Flag.Service.IPFamily {
+	case "":
+		if ipv4 != "" {
+			ips = append(ips, ipv4)
+		}
+		if ipv6 != "" {
+			ips = append(ips, ipv6)
+		}
+	case ipFamilyIPv4:
+		if ipv4 == "" {
+			return kvm.Target{}, microerror.Maskf(invalidKVMConfigurationError, "%s must not be empty for ip family %q", flannel.KeySubnet, ipFamilyIPv4)
+		}
+		ips = []string{ipv4}
+	case ipFamilyIPv6:
+		if ipv6 == "" {
+			return kvm.Target{}, microerror.Maskf(invalidKVMConfigurationError, "%s must not be empty for ip family %q", flannel.KeyIPv6Subnet, ipFamilyIPv6)
+		}
+		ips = []string{ipv6}
+	case ipFamilyDual:
+		if ipv4 == "" || ipv6 == "" {
+			return kvm.Target{}, microerror.Maskf(invalidKVMConfigurationError, "%s and %s must not be empty for ip family %q", flannel.KeySubnet, flannel.KeyIPv6Subnet, ipFamilyDual)
+		}
+		ips = []string{ipv4, ipv6}
+	default:
+		return kvm.Target{}, microerror.Maskf(invalidConfigError, "unknown ip family %q", c.Flag.Service.IPFamily)
+	}
+
+	target := kvm.Target{
+		IPs:    ips,
+		MTU:    env.MTU,
+		Source: c.Flag.Service.FlannelFile,
+	}
+
+	// fixed ips do not need to be part of the flannel network
+	if !c.addressConfig.IsFixed() {
+		for _, n := range []flannel.CIDR{env.Network, env.IPv6Network} {
+			if !n.IsEmpty() {
+				target.Networks = append(target.Networks, n.Net)
+			}
+		}
+	}
+
+	return target, nil
+}
+
+// reloadKVMTarget reads the flannel file again and derives the kvm target from
+// its current content. In contrast to LoadFlannelConfig it does not wait for
+// the file and does not modify the config.
+func (c *Config) reloadKVMTarget() (kvm.Target, error) {
+	confFile, err := c.readFlannelFile()
+	if err != nil {
+		return kvm.Target{}, microerror.Mask(err)
+	}
+
+	env, err := parseFlannelEnv(confFile)
+	if err != nil {
+		return kvm.Target{}, microerror.Mask(err)
+	}
+
+	ipv4, ipv6, err := c.deriveKVMIPs(env)
+	if err != nil {
+		return kvm.Target{}, microerror.Mask(err)
+	}
+
+	target, err := c.kvmTarget(env, ipv4, ipv6)
+	if err != nil {
+		return kvm.Target{}, microerror.Mask(err)
+	}
+
+	return target, nil
 }
@@ -0,0 +1,256 @@
+// Package flannel implements a parser for the subnet.env file flanneld writes
+// for every host, e.g.
+//
+//	FLANNEL_NETWORK=172.23.3.0/24
+//	FLANNEL_SUBNET=172.23.3.65/30
+//	FLANNEL_MTU=1450
+//	FLANNEL_IPMASQ=false
+//
+// The file is a shell environment file. Besides plain KEY=value pairs the
+// parser understands comments, blank lines, an optional export prefix, single
+// and double quoted values and CRLF line endings.
+package flannel
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+)
+
+const (
+	KeyIPMasq      = "FLANNEL_IPMASQ"
+	KeyIPv6Network = "FLANNEL_IPV6_NETWORK"
+	KeyIPv6Subnet  = "FLANNEL_IPV6_SUBNET"
+	KeyMTU         = "FLANNEL_MTU"
+	KeyNetwork     = "FLANNEL_NETWORK"
+	KeySubnet      = "FLANNEL_SUBNET"
+)
+
+// CIDR is an IP address together with the network it is part of, as written
+// in CIDR notation. For FLANNEL_SUBNET=172.23.3.65/30 the IP is 172.23.3.65
+// and the network is 172.23.3.64/30.
+type CIDR struct {
+	IP  net.IP
+	Net *net.IPNet
+}
+
+// IsEmpty returns true in case the CIDR was not set in the parsed file.
+func (c CIDR) IsEmpty() bool {
+	return c.IP == nil || c.Net == nil
+}
+
+func (c CIDR) String() string {
+	if c.IsEmpty() {
+		return ""
+	}
+
+	ones, _ := c.Net.Mask.Size()
+
+	return c.IP.String() + "/" + strconv.Itoa(ones)
+}
+
+// Env is the typed representation of a flannel subnet.env file. Fields of
+// keys not present in the file are left at their zero value.
+type Env struct {
+	// Network is the overlay network of the whole cluster, FLANNEL_NETWORK.
+	Network CIDR
+	// Subnet is the subnet leased to this host, FLANNEL_SUBNET. Its IP is the
+	// address of the bridge.
+	Subnet CIDR
+	// IPv6Network is the IPv6 overlay network of the whole cluster,
+	// FLANNEL_IPV6_NETWORK. It is only set for dual-stack or IPv6 clusters.
+	IPv6Network CIDR
+	// IPv6Subnet is the IPv6 subnet leased to this host, FLANNEL_IPV6_SUBNET.
+	IPv6Subnet CIDR
+	// MTU is the MTU of the overlay network, FLANNEL_MTU.
+	MTU int
+	// IPMasq tells whether flannel set up IP masquerading, FLANNEL_IPMASQ.
+	IPMasq bool
+
+	// Unknown holds all the keys of the file the parser does not know about.
+	Unknown map[string]string
+}
+
+// Parse parses the content of a flannel subnet.env file. Errors are of kind
+// invalidFormatError and carry the line number they occurred at.
+func Parse(b []byte) (Env, error) {
+	env := Env{
+		Unknown: map[string]string{},
+	}
+
+	lines := strings.Split(string(b), "\n")
+	for i, line := range lines {
+		n := i + 1
+
+		key, value, ok, err := parseLine(line)
+		if err != nil {
+			return Env{}, microerror.Maskf(invalidFormatError, "line %d: %s", n, err)
+		} else if !ok {
+			continue
+		}
+
+		switch key {
+		case KeyNetwork:
+			env.Network, err = parseCIDR(value, false)
+		case KeySubnet:
+			env.Subnet, err = parseCIDR(value, false)
+		case KeyIPv6Network:
+			env.IPv6Network, err = parseCIDR(value, true)
+		case KeyIPv6Subnet:
+			env.IPv6Subnet, err = parseCIDR(value, true)
+		case KeyMTU:
+			env.MTU, err = parseMTU(value)
+		case KeyIPMasq:
+			env.IPMasq, err = strconv.ParseBool(value)
+		default:
+			env.Unknown[key] = value
+		}
+		if err != nil {
+			return Env{}, microerror.Maskf(invalidFormatError, "line %d: invalid value for %s: %s", n, key, err)
+		}
+	}
+
+	return env, nil
+}
+
+// parseLine returns the key and the unquoted value of a single line. ok is
+// false for blank lines and comments.
+func parseLine(line string) (key string, value string, ok bool, err error) {
+	line = strings.TrimSuffix(line, "\r")
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", "", false, nil
+	}
+
+	if strings.HasPrefix(line, "export ") || strings.HasPrefix(line, "export\t") {
+		line = strings.TrimSpace(line[len("export"):])
+	}
+
+	i := strings.Index(line, "=")
+	if i < 0 {
+		return "", "", false, microerror.Newf("expected KEY=value, got %q", line)
+	}
+
+	key = line[:i]
+	if !isValidKey(key) {
+		return "", "", false, microerror.Newf("invalid key %q", key)
+	}
+
+	value, err = parseValue(line[i+1:])
+	if err != nil {
+		return "", "", false, microerror.Mask(err)
+	}
+
+	return key, value, true, nil
+}
+
+// parseValue removes quotes and trailing comments from the raw value of a
+// line. Single quoted values are taken literally. Double quoted values support
+// backslash escapes of ", \, $ and `.
+func parseValue(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", nil
+	}
+
+	var value string
+	var rest string
+	switch raw[0] {
+	case '\'':
+		end := strings.Index(raw[1:], "'")
+		if end < 0 {
+			return "", microerror.Newf("unterminated single quote")
+		}
+		value = raw[1 : end+1]
+		rest = raw[end+2:]
+	case '"':
+		var sb strings.Builder
+		end := -1
+		for i := 1; i < len(raw); i++ {
+			c := raw[i]
+			if c == '\\' && i+1 < len(raw) && strings.IndexByte("\"\\$`", raw[i+1]) >= 0 {
+				sb.WriteByte(raw[i+1])
+				i++
+				continue
+			}
+			if c == '"' {
+				end = i
+				break
+			}
+			sb.WriteByte(c)
+		}
+		if end < 0 {
+			return "", microerror.Newf("unterminated double quote")
+		}
+		value = sb.String()
+		rest = raw[end+1:]
+	default:
+		// Unquoted values end at the first whitespace followed by a comment.
+		value = raw
+		for i := 1; i < len(raw); i++ {
+			if raw[i] == '#' && (raw[i-1] == ' ' || raw[i-1] == '\t') {
+				value = strings.TrimSpace(raw[:i])
+				break
+			}
+		}
+		if strings.ContainsAny(value, " \t") {
+			return "", microerror.Newf("unquoted value %q must not contain whitespace", value)
+		}
+		return value, nil
+	}
+
+	rest = strings.TrimSpace(rest)
+	if rest != "" && !strings.HasPrefix(rest, "#") {
+		return "", microerror.Newf("unexpected characters %q after quoted value", rest)
+	}
+
+	return value, nil
+}
+
+func parseCIDR(s string, ipv6 bool) (CIDR, error) {
+	ip, n, err := net.ParseCIDR(s)
+	if err != nil {
+		return CIDR{}, microerror.Mask(err)
+	}
+	if ipv6 && ip.To4() != nil {
+		return CIDR{}, microerror.Newf("expected IPv6 CIDR, got %q", s)
+	}
+	if !ipv6 && ip.To4() == nil {
+		return CIDR{}, microerror.Newf("expected IPv4 CIDR, got %q", s)
+	}
+
+	return CIDR{IP: ip, Net: n}, nil
+}
+
+func parseMTU(s string) (int, error) {
+	mtu, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, microerror.Mask(err)
+	}
+	if mtu <= 0 {
+		return 0, microerror.Newf("MTU must be positive, got %d", mtu)
+	}
+
+	return mtu, nil
+}
+
+func isValidKey(key string) bool {
+	if key == "" {
+		return false
+	}
+
+	for i, c := range key {
+		switch {
+		case c == '_':
+		case c >= 'A' && c <= 'Z':
+		case c >= 'a' && c <= 'z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,127 @@
+package flannel
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_Flannel_Parse(t *testing.T) {
+	tests := []struct {
+		content         []byte
+		expectedNetwork string
+		expectedSubnet  string
+		expectedMTU     int
+		expectedIPMasq  bool
+		expectedUnknown map[string]string
+		expectedErr     bool
+	}{
+		// test 0 - file as written by flanneld
+		{
+			content: []byte(`FLANNEL_NETWORK=172.23.3.0/24
+FLANNEL_SUBNET=172.23.3.65/30
+FLANNEL_MTU=1450
+FLANNEL_IPMASQ=false
+`),
+			expectedNetwork: "172.23.3.0/24",
+			expectedSubnet:  "172.23.3.65/30",
+			expectedMTU:     1450,
+			expectedIPMasq:  false,
+			expectedUnknown: map[string]string{},
+		},
+		// test 1 - comments, export prefix, quoting and CRLF line endings
+		{
+			content: []byte("# written by flanneld\r\n" +
+				"export FLANNEL_NETWORK=\"10.1.0.0/16\"\r\n" +
+				"\r\n" +
+				"FLANNEL_SUBNET='10.1.7.1/24' # bridge\r\n" +
+				"  FLANNEL_MTU=1400 # vxlan\r\n" +
+				"FLANNEL_IPMASQ=true\r\n" +
+				"FLANNEL_BACKEND=\"vx \\\"lan\\\"\"\r\n"),
+			expectedNetwork: "10.1.0.0/16",
+			expectedSubnet:  "10.1.7.1/24",
+			expectedMTU:     1400,
+			expectedIPMasq:  true,
+			expectedUnknown: map[string]string{
+				"FLANNEL_BACKEND": `vx "lan"`,
+			},
+		},
+		// test 2 - empty file
+		{
+			content:         []byte(``),
+			expectedUnknown: map[string]string{},
+		},
+		// test 3 - line without assignment
+		{
+			content: []byte(`FLANNEL_NETWORK=172.23.3.0/24
+machine:
+`),
+			expectedErr: true,
+		},
+		// test 4 - invalid subnet
+		{
+			content:     []byte(`FLANNEL_SUBNET=_x.68.c.0/30`),
+			expectedErr: true,
+		},
+		// test 5 - invalid mtu
+		{
+			content:     []byte(`FLANNEL_MTU=-1`),
+			expectedErr: true,
+		},
+		// test 6 - unterminated quote
+		{
+			content:     []byte(`FLANNEL_SUBNET="172.23.3.65/30`),
+			expectedErr: true,
+		},
+		// test 7 - invalid key
+		{
+			content:     []byte(`1FLANNEL=foo`),
+			expectedErr: true,
+		},
+	}
+
+	for index, test := range tests {
+		env, err := Parse(test.content)
+
+		if test.expectedErr {
+			if !IsInvalidFormat(err) {
+				t.Fatalf("%d: expected invalid format error but got %#v", index, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%d: unexpected error %#v", index, err)
+		}
+
+		network := ""
+		if !env.Network.IsEmpty() {
+			network = env.Network.Net.String()
+		}
+		if network != test.expectedNetwork {
+			t.Fatalf("%d: expected network %q but got %q", index, test.expectedNetwork, network)
+		}
+		if env.Subnet.String() != test.expectedSubnet {
+			t.Fatalf("%d: expected subnet %q but got %q", index, test.expectedSubnet, env.Subnet.String())
+		}
+		if env.MTU != test.expectedMTU {
+			t.Fatalf("%d: expected mtu %d but got %d", index, test.expectedMTU, env.MTU)
+		}
+		if env.IPMasq != test.expectedIPMasq {
+			t.Fatalf("%d: expected ipmasq %t but got %t", index, test.expectedIPMasq, env.IPMasq)
+		}
+		if !reflect.DeepEqual(env.Unknown, test.expectedUnknown) {
+			t.Fatalf("%d: expected unknown keys %v but got %v", index, test.expectedUnknown, env.Unknown)
+		}
+	}
+}
+
+func Test_Flannel_Parse_LineNumber(t *testing.T) {
+	_, err := Parse([]byte("# comment\nFLANNEL_MTU=1450\nFLANNEL_MTU=abc\n"))
+	if !IsInvalidFormat(err) {
+		t.Fatalf("expected invalid format error but got %#v", err)
+	}
+
+	expected := "line 3: "
+	if msg := err.Error(); len(msg) < len(expected) || msg[:len(expected)] != expected {
+		t.Fatalf("expected error message to start with %q but got %q", expected, msg)
+	}
+}
@@ -0,0 +1,10 @@
+package flannel
+
+import "github.com/giantswarm/microerror"
+
+var invalidFormatError = microerror.New("invalid format")
+
+// IsInvalidFormat asserts invalidFormatError.
+func IsInvalidFormat(err error) bool {
+	return microerror.Cause(err) == invalidFormatError
+}
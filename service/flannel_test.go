@@ -1,12 +1,17 @@
 package service
 
 import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/giantswarm/microerror"
 
 	"github.com/giantswarm/k8s-kvm-health/flag"
+	"github.com/giantswarm/k8s-kvm-health/service/address"
 )
 
 func Test_Flannel_ParseIP(t *testing.T) {
@@ -124,3 +129,298 @@ dependencies:
 		}
 	}
 }
+
+func Test_Flannel_ParseIPv6(t *testing.T) {
+	tests := []struct {
+		flannelFileContent []byte
+		expectedIPv4       string
+		expectedIPv6       string
+		expectedErr        error
+	}{
+		// test 0 - dual-stack
+		{
+			flannelFileContent: []byte(`FLANNEL_NETWORK=172.23.3.0/24
+FLANNEL_SUBNET=172.23.3.65/30
+FLANNEL_IPV6_NETWORK=fd00:10:244::/56
+FLANNEL_IPV6_SUBNET=fd00:10:244:1::1/64
+FLANNEL_MTU=1450
+FLANNEL_IPMASQ=false`),
+			expectedIPv4: "172.23.3.66",
+			expectedIPv6: "fd00:10:244:1::2",
+			expectedErr:  nil,
+		},
+		// test 1 - IPv6 only
+		{
+			flannelFileContent: []byte(`FLANNEL_IPV6_NETWORK=fd00:10:244::/56
+FLANNEL_IPV6_SUBNET=fd00:10:244:1::1/64
+FLANNEL_MTU=1450`),
+			expectedIPv4: "",
+			expectedIPv6: "fd00:10:244:1::2",
+			expectedErr:  nil,
+		},
+		// test 2 - bridge ip ending in .255 must not wrap
+		{
+			flannelFileContent: []byte(`FLANNEL_NETWORK=10.0.0.0/16
+FLANNEL_SUBNET=10.0.0.255/23`),
+			expectedIPv4: "10.0.1.0",
+			expectedErr:  nil,
+		},
+		// test 3 - kvm ip would be the broadcast address of the subnet
+		{
+			flannelFileContent: []byte(`FLANNEL_NETWORK=10.0.0.0/16
+FLANNEL_SUBNET=10.0.0.254/24`),
+			expectedErr: failedParsingFlannelSubnetError,
+		},
+		// test 4 - IPv4 address given as IPv6 subnet
+		{
+			flannelFileContent: []byte(`FLANNEL_IPV6_SUBNET=10.0.0.1/24`),
+			expectedErr:        invalidKVMConfigurationError,
+		},
+	}
+
+	for index, test := range tests {
+		conf := DefaultConfig()
+		conf.Flag = flag.New()
+		err := conf.parseIPs(test.flannelFileContent)
+
+		if microerror.Cause(err) != microerror.Cause(test.expectedErr) {
+			t.Fatalf("%d: unexcepted error, expected %#v but got %#v", index, test.expectedErr, err)
+		}
+		if test.expectedErr == nil {
+			if conf.Flag.Service.IPAddress != test.expectedIPv4 {
+				t.Fatalf("%d: Incorrent ipv4, expected %s but got %s.", index, test.expectedIPv4, conf.Flag.Service.IPAddress)
+			}
+			if conf.Flag.Service.IPv6Address != test.expectedIPv6 {
+				t.Fatalf("%d: Incorrent ipv6, expected %s but got %s.", index, test.expectedIPv6, conf.Flag.Service.IPv6Address)
+			}
+		}
+	}
+}
+
+func Test_Flannel_ParseIP_Strategy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "k8s-kvm-health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeFile := func(name string, content string) string {
+		path := filepath.Join(dir, name)
+		err := ioutil.WriteFile(path, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	flannelFileContent := []byte(`FLANNEL_NETWORK=172.23.0.0/16
+FLANNEL_SUBNET=172.23.3.65/26
+FLANNEL_IPV6_NETWORK=fd00:10:244::/56
+FLANNEL_IPV6_SUBNET=fd00:10:244:1::1/64
+FLANNEL_MTU=1450
+FLANNEL_IPMASQ=false`)
+
+	tests := []struct {
+		addressConfig address.Config
+		expectedIPv4  string
+		expectedIPv6  string
+		expectedErr   error
+	}{
+		// test 0 - default strategy
+		{
+			addressConfig: address.Config{},
+			expectedIPv4:  "172.23.3.66",
+			expectedIPv6:  "fd00:10:244:1::2",
+			expectedErr:   nil,
+		},
+		// test 1 - offset from flannel ip
+		{
+			addressConfig: address.Config{
+				Strategy: address.StrategyFlannelIPOffset,
+				Offset:   3,
+			},
+			expectedIPv4: "172.23.3.68",
+			expectedIPv6: "fd00:10:244:1::4",
+			expectedErr:  nil,
+		},
+		// test 2 - default offset from subnet network address
+		{
+			addressConfig: address.Config{
+				Strategy: address.StrategySubnetOffset,
+			},
+			expectedIPv4: "172.23.3.66",
+			expectedIPv6: "fd00:10:244:1::2",
+			expectedErr:  nil,
+		},
+		// test 3 - offset from subnet network address
+		{
+			addressConfig: address.Config{
+				Strategy: address.StrategySubnetOffset,
+				Offset:   10,
+			},
+			expectedIPv4: "172.23.3.74",
+			expectedIPv6: "fd00:10:244:1::a",
+			expectedErr:  nil,
+		},
+		// test 4 - offset beyond the subnet
+		{
+			addressConfig: address.Config{
+				Strategy: address.StrategySubnetOffset,
+				Offset:   64,
+			},
+			expectedErr: failedParsingFlannelSubnetError,
+		},
+		// test 5 - fixed ips
+		{
+			addressConfig: address.Config{
+				Strategy: address.StrategyFixed,
+				FixedIPs: []net.IP{net.ParseIP("10.0.0.5")},
+			},
+			expectedIPv4: "10.0.0.5",
+			expectedIPv6: "",
+			expectedErr:  nil,
+		},
+		// test 6 - dnsmasq lease file, the last lease within the subnet wins
+		{
+			addressConfig: address.Config{
+				Strategy: address.StrategyDHCPLease,
+				File: writeFile("dnsmasq.leases", `1610000000 52:54:00:12:34:56 172.23.3.70 guest *
+1610000100 52:54:00:12:34:57 172.23.3.71 guest *
+1610000200 52:54:00:12:34:58 10.0.0.9 other *
+duid 00:01:00:01:27:8a:4b:5c:52:54:00:12:34:56
+1610000300 1234 fd00:10:244:1::42 guest 00:01:00:01
+`),
+			},
+			expectedIPv4: "172.23.3.71",
+			expectedIPv6: "fd00:10:244:1::42",
+			expectedErr:  nil,
+		},
+		// test 7 - ISC dhcpd lease file without an IPv6 lease
+		{
+			addressConfig: address.Config{
+				Strategy: address.StrategyDHCPLease,
+				File: writeFile("dhcpd.leases", `lease 172.23.3.80 {
+  starts 4 2021/01/07 10:00:00;
+  hardware ethernet 52:54:00:12:34:56;
+}
+`),
+			},
+			expectedErr: invalidKVMConfigurationError,
+		},
+		// test 8 - static file written by kvm-operator
+		{
+			addressConfig: address.Config{
+				Strategy: address.StrategyStaticFile,
+				File: writeFile("ips", `# written by kvm-operator
+172.23.3.90
+fd00:10:244:1::90
+`),
+			},
+			expectedIPv4: "172.23.3.90",
+			expectedIPv6: "fd00:10:244:1::90",
+			expectedErr:  nil,
+		},
+		// test 9 - missing static file
+		{
+			addressConfig: address.Config{
+				Strategy: address.StrategyStaticFile,
+				File:     filepath.Join(dir, "missing"),
+			},
+			expectedErr: invalidKVMConfigurationError,
+		},
+	}
+
+	for index, test := range tests {
+		conf := DefaultConfig()
+		conf.Flag = flag.New()
+		conf.addressConfig = test.addressConfig
+		err := conf.parseIPs(flannelFileContent)
+
+		if microerror.Cause(err) != microerror.Cause(test.expectedErr) {
+			t.Fatalf("%d: unexcepted error, expected %#v but got %#v", index, test.expectedErr, err)
+		}
+		if test.expectedErr == nil {
+			if conf.Flag.Service.IPAddress != test.expectedIPv4 {
+				t.Fatalf("%d: Incorrent ipv4, expected %s but got %s.", index, test.expectedIPv4, conf.Flag.Service.IPAddress)
+			}
+			if conf.Flag.Service.IPv6Address != test.expectedIPv6 {
+				t.Fatalf("%d: Incorrent ipv6, expected %s but got %s.", index, test.expectedIPv6, conf.Flag.Service.IPv6Address)
+			}
+		}
+	}
+}
+
+func Test_Flannel_NewAddressConfig(t *testing.T) {
+	tests := []struct {
+		strategy    string
+		offset      string
+		fixedIPs    string
+		file        string
+		expectedErr error
+	}{
+		// test 0 - defaults
+		{
+			expectedErr: nil,
+		},
+		// test 1
+		{
+			strategy:    "subnet-offset",
+			offset:      "5",
+			expectedErr: nil,
+		},
+		// test 2 - unknown strategy
+		{
+			strategy:    "magic",
+			expectedErr: invalidConfigError,
+		},
+		// test 3 - invalid offset
+		{
+			offset:      "one",
+			expectedErr: invalidConfigError,
+		},
+		// test 4 - fixed strategy without ips
+		{
+			strategy:    "fixed",
+			expectedErr: invalidConfigError,
+		},
+		// test 5
+		{
+			strategy:    "fixed",
+			fixedIPs:    "10.0.0.5, fd00::5",
+			expectedErr: nil,
+		},
+		// test 6 - two fixed ips of the same family
+		{
+			strategy:    "fixed",
+			fixedIPs:    "10.0.0.5,10.0.0.6",
+			expectedErr: invalidConfigError,
+		},
+		// test 7 - file strategy without file
+		{
+			strategy:    "dhcp-lease",
+			expectedErr: invalidConfigError,
+		},
+		// test 8 - offset with a file strategy
+		{
+			strategy:    "static-file",
+			offset:      "2",
+			file:        "/run/kvm/ips",
+			expectedErr: invalidConfigError,
+		},
+	}
+
+	for index, test := range tests {
+		conf := DefaultConfig()
+		conf.Flag = flag.New()
+		conf.Flag.Service.AddressStrategy = test.strategy
+		conf.Flag.Service.AddressOffset = test.offset
+		conf.Flag.Service.AddressFixedIPs = test.fixedIPs
+		conf.Flag.Service.AddressFile = test.file
+
+		_, err := conf.newAddressConfig()
+
+		if microerror.Cause(err) != microerror.Cause(test.expectedErr) {
+			t.Fatalf("%d: unexcepted error, expected %#v but got %#v", index, test.expectedErr, err)
+		}
+	}
+}
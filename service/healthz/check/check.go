@@ -0,0 +1,173 @@
+// Package check defines the result of a single health check and the response
+// aggregating the results of several checks.
+package check
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/giantswarm/microendpoint/service/healthz"
+)
+
+// Status is the outcome of a single check.
+type Status string
+
+const (
+	// StatusOK means the check succeeded.
+	StatusOK Status = "ok"
+	// StatusFailed means the check failed.
+	StatusFailed Status = "failed"
+	// StatusTimeout means the check did not finish within its deadline, e.g.
+	// the probe budget.
+	StatusTimeout Status = "timeout"
+	// StatusSkipped means the check was not performed because one of its
+	// dependencies did not succeed.
+	StatusSkipped Status = "skipped"
+)
+
+// State is the health of a check derived from its consecutive results. It
+// only changes once a result was repeated often enough, so that single
+// failures do not make a check unhealthy.
+type State string
+
+const (
+	// StateHealthy means the check succeeded often enough in a row.
+	StateHealthy State = "healthy"
+	// StateDegraded means the check recently failed, or recently recovered,
+	// but not often enough in a row to change its health.
+	StateDegraded State = "degraded"
+	// StateUnhealthy means the check failed often enough in a row.
+	StateUnhealthy State = "unhealthy"
+	// StateInitializing means the check was not performed yet because the KVM
+	// to probe is not known yet.
+	StateInitializing State = "initializing"
+)
+
+// Result is the result of a single check performed against a single target.
+type Result struct {
+	// Name is the name of the check, e.g. ping.
+	Name string
+	// Target is the address the check was performed against.
+	Target string
+	// Status is the outcome of the check.
+	Status Status
+	// Latency is the time it took to perform the check.
+	Latency time.Duration
+	// Message describes the outcome of a successful check.
+	Message string
+	// Error describes why the check failed. It is empty for successful checks.
+	Error string
+	// Warning describes a problem that does not fail the check yet, e.g. a
+	// certificate close to expiry. It is optional.
+	Warning string
+	// Timestamp is the time the check was started.
+	Timestamp time.Time
+	// Age is the time passed since the check was started, at the time the
+	// result is served. It is only set for results served from a cache.
+	Age time.Duration
+	// Details holds additional information specific to the check, e.g. ping
+	// statistics. It is optional.
+	Details map[string]interface{}
+	// State is the health of the check derived from its previous results, and
+	// ConsecutiveSuccesses and ConsecutiveFailures are the current streaks it
+	// is derived from. They are set by the service keeping the history of the
+	// check and are empty for results not recorded in any history.
+	State                State
+	ConsecutiveSuccesses int
+	ConsecutiveFailures  int
+	// LastSuccess is the time the check last succeeded. It is set together
+	// with the State.
+	LastSuccess time.Time
+	// Severity tells how much the check affects the overall health in case it
+	// is unhealthy. It is set by the service performing the check.
+	Severity Severity
+	// SubChecks are the results of the components the checked endpoint
+	// reported on, e.g. the ones of a verbose K8s API readyz response. They are
+	// optional.
+	SubChecks []SubResult
+}
+
+// SubResult is the result of a single component reported by the endpoint a
+// check was performed against.
+type SubResult struct {
+	Name    string `json:"name"`
+	Status  Status `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// Failed returns true in case the component did not succeed.
+func (r SubResult) Failed() bool {
+	return r.Status != StatusOK
+}
+
+// Failed returns true in case the check did not succeed.
+func (r Result) Failed() bool {
+	return r.Status != StatusOK
+}
+
+// MarshalJSON encodes the latency and the age in milliseconds so that they can
+// be read by humans and tools alike.
+func (r Result) MarshalJSON() ([]byte, error) {
+	var lastSuccess *time.Time
+	if !r.LastSuccess.IsZero() {
+		lastSuccess = &r.LastSuccess
+	}
+
+	return json.Marshal(struct {
+		Name      string                 `json:"name"`
+		Target    string                 `json:"target,omitempty"`
+		Status    Status                 `json:"status"`
+		LatencyMS float64                `json:"latency_ms"`
+		Message   string                 `json:"message,omitempty"`
+		Error     string                 `json:"error,omitempty"`
+		Warning   string                 `json:"warning,omitempty"`
+		Timestamp time.Time              `json:"timestamp"`
+		AgeMS     float64                `json:"age_ms"`
+		Details   map[string]interface{} `json:"details,omitempty"`
+		SubChecks []SubResult            `json:"sub_checks,omitempty"`
+
+		State                State      `json:"state,omitempty"`
+		ConsecutiveSuccesses int        `json:"consecutive_successes"`
+		ConsecutiveFailures  int        `json:"consecutive_failures"`
+		LastSuccess          *time.Time `json:"last_success,omitempty"`
+
+		Severity Severity `json:"severity,omitempty"`
+	}{
+		Name:      r.Name,
+		Target:    r.Target,
+		Status:    r.Status,
+		LatencyMS: Milliseconds(r.Latency),
+		Message:   r.Message,
+		Error:     r.Error,
+		Warning:   r.Warning,
+		Timestamp: r.Timestamp,
+		AgeMS:     Milliseconds(r.Age),
+		Details:   r.Details,
+		SubChecks: r.SubChecks,
+
+		State:                r.State,
+		ConsecutiveSuccesses: r.ConsecutiveSuccesses,
+		ConsecutiveFailures:  r.ConsecutiveFailures,
+		LastSuccess:          lastSuccess,
+
+		Severity: r.Severity,
+	})
+}
+
+// Milliseconds returns the given duration in fractional milliseconds, the unit
+// durations are reported in.
+func Milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Response is the healthz response extended by the results of the single
+// checks. The fields of the healthz response are kept on the top level, so
+// that clients only knowing the healthz response keep working.
+type Response struct {
+	healthz.Response
+	// State is the overall health derived from the states and severities of
+	// the checks, see Severity.Effect. It is empty in case no check has a
+	// state yet.
+	State  State    `json:"state,omitempty"`
+	Checks []Result `json:"checks,omitempty"`
+}
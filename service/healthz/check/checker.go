@@ -0,0 +1,89 @@
+package check
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// Checker is a single health check performed against a single IP of the KVM.
+type Checker interface {
+	// Name identifies the checker in the registry and in the results.
+	Name() string
+	// Description describes which functionality the checker ensures.
+	Description() string
+	// Check performs the check against the given target. It only has to set
+	// the Status, Message and Error of the result. The other fields are set by
+	// Run.
+	Check(ctx context.Context, target Target) Result
+}
+
+// Configurable is implemented by checkers able to report their effective
+// configuration, e.g. for the config endpoint. The settings are encoded as
+// JSON and must not contain secrets.
+type Configurable interface {
+	Settings() interface{}
+}
+
+// Dependent is implemented by checkers depending on other checkers, e.g. a
+// check of a service inside the guest depending on the KVM being reachable.
+type Dependent interface {
+	// Dependencies returns the names of the checkers the checker depends on.
+	Dependencies() []string
+}
+
+// Target is a single IP of the KVM a checker is performed against.
+type Target struct {
+	IP string
+	// Network is the flannel network the IP is part of. It is optional and
+	// only used for reporting.
+	Network *net.IPNet
+	// MTU is the MTU of the flannel network. It is optional and only used for
+	// reporting.
+	MTU int
+}
+
+// Run performs the given checker against the given target and completes its
+// result by the name of the checker, the target, the timestamp and the
+// latency. Failed results are marked as timed out in case the deadline of ctx
+// exceeded. The result is recorded in the metrics.
+func Run(ctx context.Context, checker Checker, target Target) Result {
+	start := time.Now()
+
+	result := checker.Check(ctx, target)
+
+	result.Name = checker.Name()
+	result.Target = target.IP
+	result.Timestamp = start
+	result.Latency = time.Since(start)
+	if result.Status == "" {
+		result.Status = StatusFailed
+	}
+	if result.Failed() && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		result.Status = StatusTimeout
+		result.Error = fmt.Sprintf("Timed out after %s. %s", result.Latency.Round(time.Millisecond), result.Error)
+	}
+
+	observe(result)
+
+	return result
+}
+
+// Skip returns the result of the given checker in case it is not performed
+// against the given target because the given dependency did not succeed. The
+// result is recorded in the metrics, apart from the duration.
+func Skip(checker Checker, target Target, dependency Result) Result {
+	result := Result{
+		Name:      checker.Name(),
+		Target:    target.IP,
+		Status:    StatusSkipped,
+		Error:     fmt.Sprintf("skipped (dependency %s %s)", dependency.Name, dependency.Status),
+		Timestamp: time.Now(),
+	}
+
+	checkTotal.WithLabelValues(result.Name, result.Target, string(result.Status)).Inc()
+
+	return result
+}
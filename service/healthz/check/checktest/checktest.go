@@ -0,0 +1,115 @@
+// Package checktest provides a configurable checker for the tests of the
+// packages performing checks.
+package checktest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check"
+)
+
+// Config represents the configuration used to create a new checker.
+type Config struct {
+	// Block makes every check wait for its context to be done and fail with
+	// the error of the context.
+	Block bool
+	// Dependencies are the names of the checkers the checker depends on.
+	Dependencies []string
+	// Name is the name of the checker. Defaults to test.
+	Name string
+	// Sleep is the time every check takes.
+	Sleep time.Duration
+	// Status is the status of the results. Defaults to check.StatusOK.
+	Status check.Status
+	// Warning is the warning of the results.
+	Warning string
+}
+
+// Checker implements check.Checker and check.Dependent. It counts the checks
+// performed and returns results of the configured status.
+type Checker struct {
+	block        bool
+	dependencies []string
+	name         string
+	sleep        time.Duration
+	warning      string
+
+	count  int
+	mutex  sync.Mutex
+	status check.Status
+}
+
+// New creates a new configured checker.
+func New(config Config) *Checker {
+	if config.Name == "" {
+		config.Name = "test"
+	}
+	if config.Status == "" {
+		config.Status = check.StatusOK
+	}
+
+	return &Checker{
+		block:        config.Block,
+		dependencies: config.Dependencies,
+		name:         config.Name,
+		sleep:        config.Sleep,
+		warning:      config.Warning,
+
+		status: config.Status,
+	}
+}
+
+// Check returns a result of the current status after the configured sleep.
+func (c *Checker) Check(ctx context.Context, target check.Target) check.Result {
+	c.mutex.Lock()
+	c.count++
+	status := c.status
+	c.mutex.Unlock()
+
+	if c.block {
+		<-ctx.Done()
+		return check.Result{Status: check.StatusFailed, Error: ctx.Err().Error()}
+	}
+
+	time.Sleep(c.sleep)
+
+	r := check.Result{Status: status, Warning: c.warning}
+	if status == check.StatusFailed {
+		r.Error = "test failed"
+	}
+
+	return r
+}
+
+// Count returns the number of checks performed.
+func (c *Checker) Count() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.count
+}
+
+// Dependencies returns the configured dependencies.
+func (c *Checker) Dependencies() []string {
+	return c.dependencies
+}
+
+// Description returns the name of the checker.
+func (c *Checker) Description() string {
+	return c.name
+}
+
+// Name returns the name of the checker.
+func (c *Checker) Name() string {
+	return c.name
+}
+
+// SetStatus changes the status of the results of the following checks.
+func (c *Checker) SetStatus(status check.Status) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.status = status
+}
@@ -0,0 +1,18 @@
+package check
+
+import "context"
+
+type freshKey struct{}
+
+// WithFresh returns a context asking health checks to perform their checks
+// synchronously instead of serving cached results.
+func WithFresh(ctx context.Context) context.Context {
+	return context.WithValue(ctx, freshKey{}, true)
+}
+
+// IsFresh tells whether the given context asks for fresh results, see
+// WithFresh.
+func IsFresh(ctx context.Context) bool {
+	fresh, _ := ctx.Value(freshKey{}).(bool)
+	return fresh
+}
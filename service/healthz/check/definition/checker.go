@@ -0,0 +1,206 @@
+package definition
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"text/template"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check"
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check/dns"
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check/httpget"
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check/ping"
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check/tcp"
+)
+
+const (
+	defaultHost = "{{.IP}}"
+	defaultPath = "/"
+)
+
+// Config represents the configuration used to create the checker of a
+// definition.
+type Config struct {
+	// Dependencies.
+	Logger micrologger.Logger
+
+	// Settings.
+	Definition Definition
+}
+
+// Checker performs the check declared by a definition. It renders the host
+// of the definition for the KVM IP and performs the checker of the type of
+// the definition against it.
+type Checker struct {
+	check.Checker
+
+	definition Definition
+	host       *template.Template
+}
+
+// templateData is what the host template of a definition may refer to.
+type templateData struct {
+	IP      string
+	MTU     int
+	Network string
+}
+
+// New creates the checker of the given definition.
+func New(config Config) (*Checker, error) {
+	// Dependencies.
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.Logger must not be empty")
+	}
+
+	// Settings.
+	d := config.Definition
+	if d.Name == "" {
+		return nil, microerror.Maskf(invalidConfigError, "config.Definition.Name must not be empty")
+	}
+
+	host := d.Host
+	if host == "" {
+		host = defaultHost
+	}
+	hostTemplate, err := template.New(d.Name).Option("missingkey=error").Parse(host)
+	if err != nil {
+		return nil, microerror.Maskf(invalidConfigError, "host of check %#q must be a valid template: %s", d.Name, err)
+	}
+
+	var timeout time.Duration
+	if d.Timeout != "" {
+		timeout, err = time.ParseDuration(d.Timeout)
+		if err != nil {
+			return nil, microerror.Maskf(invalidConfigError, "timeout of check %#q must be a duration, got %q", d.Name, d.Timeout)
+		}
+	}
+
+	description := d.Description
+	if description == "" {
+		description = fmt.Sprintf("Ensure %s check %s succeeds.", d.Type, d.Name)
+	}
+
+	var c check.Checker
+	switch d.Type {
+	case TypeDNS:
+		c, err = dns.New(dns.Config{
+			Logger: config.Logger,
+
+			Description:       description,
+			ExpectedAddresses: d.Expect.Addresses,
+			Name:              d.Name,
+			Port:              d.Port,
+			Query:             d.Query,
+			Timeout:           timeout,
+		})
+	case TypeHTTP, TypeHTTPS:
+		path := d.Path
+		if path == "" {
+			path = defaultPath
+		}
+
+		c, err = httpget.New(httpget.Config{
+			Logger: config.Logger,
+
+			Description:         description,
+			ExpectedBody:        d.Expect.Body,
+			ExpectedStatusCodes: d.Expect.Status,
+			Name:                d.Name,
+			Path:                path,
+			Port:                d.Port,
+			Scheme:              string(d.Type),
+			Timeout:             timeout,
+			TLS: httpget.TLSConfig{
+				CAFile:     d.CAFile,
+				ServerName: d.ServerName,
+			},
+		})
+	case TypeICMP:
+		c, err = ping.New(ping.Config{
+			Logger: config.Logger,
+
+			Description: description,
+			Name:        d.Name,
+			Count:       d.Count,
+			Timeout:     timeout,
+			MaxLoss:     d.Expect.MaxLoss,
+			// checks of type icmp only ever send ICMP, unlike the ping check
+			// they do not fall back to a TCP connect, which checks of type tcp
+			// do explicitly
+			TCPPort: -1,
+		})
+	case TypeTCP:
+		c, err = tcp.New(tcp.Config{
+			Logger: config.Logger,
+
+			Description: description,
+			Name:        d.Name,
+			Port:        d.Port,
+			Timeout:     timeout,
+		})
+	default:
+		return nil, microerror.Maskf(invalidConfigError, "type of check %#q must be one of %v, got %q", d.Name, Types, d.Type)
+	}
+	if err != nil {
+		return nil, microerror.Maskf(invalidConfigError, "check %#q: %s", d.Name, err)
+	}
+
+	newChecker := &Checker{
+		Checker: c,
+
+		definition: d,
+		host:       hostTemplate,
+	}
+
+	return newChecker, nil
+}
+
+// Check performs the checker of the definition against the rendered host. The
+// host is reported in the details of the result in case it differs from the
+// KVM IP.
+func (c *Checker) Check(ctx context.Context, target check.Target) check.Result {
+	data := templateData{
+		IP:  target.IP,
+		MTU: target.MTU,
+	}
+	if target.Network != nil {
+		data.Network = target.Network.String()
+	}
+
+	var b bytes.Buffer
+	err := c.host.Execute(&b, data)
+	if err != nil {
+		return check.Result{
+			Status: check.StatusFailed,
+			Error:  fmt.Sprintf("Failed to render host of check %s. %s", c.definition.Name, err),
+		}
+	}
+	host := b.String()
+
+	t := target
+	t.IP = host
+	result := c.Checker.Check(ctx, t)
+
+	if host != target.IP {
+		if result.Details == nil {
+			result.Details = map[string]interface{}{}
+		}
+		result.Details["host"] = host
+	}
+
+	return result
+}
+
+// Dependencies returns the names of the checks the definition depends on.
+func (c *Checker) Dependencies() []string {
+	return c.definition.DependsOn
+}
+
+// Settings returns the definition of the checker.
+func (c *Checker) Settings() interface{} {
+	return c.definition
+}
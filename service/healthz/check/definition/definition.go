@@ -0,0 +1,171 @@
+// Package definition implements checks declared in a checks file, e.g.
+//
+//	checks:
+//	- name: etcd
+//	  type: https
+//	  port: 2379
+//	  path: /health
+//	  depends_on: [ping]
+//	- name: ssh
+//	  type: tcp
+//	  port: 22
+//	  timeout: 1s
+//	  severity: warning
+//
+// The file is YAML, which makes JSON files valid as well.
+package definition
+
+import (
+	"io/ioutil"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"gopkg.in/yaml.v2"
+
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check"
+)
+
+// Type is the kind of a declared check.
+type Type string
+
+const (
+	TypeDNS   Type = "dns"
+	TypeHTTP  Type = "http"
+	TypeHTTPS Type = "https"
+	TypeICMP  Type = "icmp"
+	TypeTCP   Type = "tcp"
+)
+
+// Types are all known types.
+var Types = []Type{TypeDNS, TypeHTTP, TypeHTTPS, TypeICMP, TypeTCP}
+
+// File is the content of a checks file.
+type File struct {
+	Checks []Definition `json:"checks" yaml:"checks"`
+}
+
+// Definition declares a single check.
+type Definition struct {
+	// Name identifies the check, e.g. in CHECKS and the health endpoints. It
+	// must not clash with the built-in checks.
+	Name        string `json:"name" yaml:"name"`
+	Type        Type   `json:"type" yaml:"type"`
+	Description string `json:"description,omitempty" yaml:"description"`
+	// Host is the host the check is performed against. It is a template which
+	// may refer to the KVM IP as {{.IP}}, its network as {{.Network}} and the
+	// MTU as {{.MTU}}. Defaults to the KVM IP. Checks of type icmp require it
+	// to be an IP.
+	Host string `json:"host,omitempty" yaml:"host"`
+	// Port is required for the types http, https and tcp. Checks of type dns
+	// default to 53.
+	Port int `json:"port,omitempty" yaml:"port"`
+	// Path is the path requested by checks of type http and https. Defaults
+	// to /.
+	Path string `json:"path,omitempty" yaml:"path"`
+	// Query is the name resolved by checks of type dns.
+	Query string `json:"query,omitempty" yaml:"query"`
+	// Count is the number of packets sent by checks of type icmp.
+	Count int `json:"count,omitempty" yaml:"count"`
+	// Timeout is the time after which the check fails, e.g. 2s.
+	Timeout string `json:"timeout,omitempty" yaml:"timeout"`
+	// CAFile and ServerName configure the verification of the certificate of
+	// checks of type https. The certificate is not verified without CAFile.
+	CAFile     string `json:"ca_file,omitempty" yaml:"ca_file"`
+	ServerName string `json:"server_name,omitempty" yaml:"server_name"`
+	Expect     Expect `json:"expect,omitempty" yaml:"expect"`
+	// DependsOn are the names of the checks the check depends on.
+	DependsOn []string `json:"depends_on,omitempty" yaml:"depends_on"`
+	// Severity is one of critical, warning and info. Defaults to critical.
+	Severity string `json:"severity,omitempty" yaml:"severity"`
+}
+
+// Expect declares what a check expects from the checked endpoint.
+type Expect struct {
+	// Status are the status codes accepted by checks of type http and https.
+	// Defaults to 200.
+	Status []int `json:"status,omitempty" yaml:"status"`
+	// Body is the body expected by checks of type http and https, ignoring
+	// leading and trailing whitespace.
+	Body string `json:"body,omitempty" yaml:"body"`
+	// Addresses are the addresses checks of type dns expect the query to
+	// resolve to.
+	Addresses []string `json:"addresses,omitempty" yaml:"addresses"`
+	// MaxLoss is the percentage of lost packets up to which checks of type
+	// icmp succeed.
+	MaxLoss float64 `json:"max_loss,omitempty" yaml:"max_loss"`
+}
+
+// Load reads the checks file of the given path. Unknown fields, unknown types
+// and duplicate names are rejected. Whether the checks can be created is only
+// validated by New.
+func Load(path string) ([]Definition, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	definitions, err := Parse(b)
+	if err != nil {
+		return nil, microerror.Maskf(invalidFileError, "%s: %s", path, err)
+	}
+
+	return definitions, nil
+}
+
+// Parse parses the content of a checks file, see Load.
+func Parse(b []byte) ([]Definition, error) {
+	var file File
+	err := yaml.UnmarshalStrict(b, &file)
+	if err != nil {
+		return nil, microerror.Maskf(invalidFileError, "%s", err)
+	}
+
+	names := map[string]bool{}
+	for i, d := range file.Checks {
+		if d.Name == "" {
+			return nil, microerror.Maskf(invalidFileError, "check %d must have a name", i)
+		}
+		if names[d.Name] {
+			return nil, microerror.Maskf(invalidFileError, "check %#q must be declared only once", d.Name)
+		}
+		names[d.Name] = true
+
+		if !isValidType(d.Type) {
+			return nil, microerror.Maskf(invalidFileError, "type of check %#q must be one of %v, got %q", d.Name, Types, d.Type)
+		}
+		if d.Timeout != "" {
+			_, err := time.ParseDuration(d.Timeout)
+			if err != nil {
+				return nil, microerror.Maskf(invalidFileError, "timeout of check %#q must be a duration, got %q", d.Name, d.Timeout)
+			}
+		}
+		if d.Severity != "" {
+			_, err := check.ParseSeverity(d.Severity)
+			if err != nil {
+				return nil, microerror.Maskf(invalidFileError, "severity of check %#q must be one of %v, got %q", d.Name, check.Severities, d.Severity)
+			}
+		}
+	}
+
+	return file.Checks, nil
+}
+
+// Names returns the names of the given definitions.
+func Names(definitions []Definition) []string {
+	var names []string
+	for _, d := range definitions {
+		names = append(names, d.Name)
+	}
+
+	return names
+}
+
+func isValidType(t Type) bool {
+	for _, known := range Types {
+		if t == known {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,235 @@
+package definition
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check"
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check/ping"
+)
+
+func Test_Definition_Parse(t *testing.T) {
+	tests := []struct {
+		file          string
+		expectedNames []string
+		expectedErr   func(error) bool
+	}{
+		// test 0 - empty file
+		{
+			file:          "",
+			expectedNames: nil,
+		},
+		// test 1 - YAML
+		{
+			file: `
+checks:
+- name: etcd
+  type: https
+  port: 2379
+  path: /health
+  timeout: 2s
+  expect:
+    status: [200]
+  depends_on: [ping]
+- name: ssh
+  type: tcp
+  port: 22
+  severity: warning
+`,
+			expectedNames: []string{"etcd", "ssh"},
+		},
+		// test 2 - JSON
+		{
+			file:          `{"checks": [{"name": "coredns", "type": "dns", "query": "kubernetes.default.svc.cluster.local"}]}`,
+			expectedNames: []string{"coredns"},
+		},
+		// test 3 - unknown field
+		{
+			file:        "checks:\n- name: ssh\n  type: tcp\n  prot: 22\n",
+			expectedErr: IsInvalidFile,
+		},
+		// test 4 - unknown type
+		{
+			file:        "checks:\n- name: ssh\n  type: udp\n  port: 22\n",
+			expectedErr: IsInvalidFile,
+		},
+		// test 5 - duplicate name
+		{
+			file:        "checks:\n- name: ssh\n  type: tcp\n  port: 22\n- name: ssh\n  type: tcp\n  port: 2222\n",
+			expectedErr: IsInvalidFile,
+		},
+		// test 6 - invalid timeout
+		{
+			file:        "checks:\n- name: ssh\n  type: tcp\n  port: 22\n  timeout: 2\n",
+			expectedErr: IsInvalidFile,
+		},
+		// test 7 - unknown severity
+		{
+			file:        "checks:\n- name: ssh\n  type: tcp\n  port: 22\n  severity: fatal\n",
+			expectedErr: IsInvalidFile,
+		},
+	}
+
+	for index, test := range tests {
+		definitions, err := Parse([]byte(test.file))
+		if test.expectedErr != nil {
+			if !test.expectedErr(err) {
+				t.Fatalf("%d: unexpected error %#v", index, err)
+			}
+			continue
+		} else if err != nil {
+			t.Fatalf("%d: unexpected error %#v", index, err)
+		}
+
+		names := Names(definitions)
+		if len(names) != len(test.expectedNames) {
+			t.Fatalf("%d: expected %v got %v", index, test.expectedNames, names)
+		}
+		for i := range names {
+			if names[i] != test.expectedNames[i] {
+				t.Fatalf("%d: expected %v got %v", index, test.expectedNames, names)
+			}
+		}
+	}
+}
+
+func Test_Definition_Check(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("OK"))
+	}))
+	defer server.Close()
+
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		definition     Definition
+		expectedStatus check.Status
+		expectedHost   interface{}
+	}{
+		// test 0 - tcp against the KVM IP
+		{
+			definition: Definition{
+				Name: "tcp",
+				Type: TypeTCP,
+				Port: p,
+			},
+			expectedStatus: check.StatusOK,
+			expectedHost:   nil,
+		},
+		// test 1 - http against a templated host
+		{
+			definition: Definition{
+				Name: "http",
+				Type: TypeHTTP,
+				Host: "{{if .IP}}localhost{{end}}",
+				Port: p,
+				Path: "/health",
+				Expect: Expect{
+					Body: "OK",
+				},
+			},
+			expectedStatus: check.StatusOK,
+			expectedHost:   "localhost",
+		},
+		// test 2 - unexpected body
+		{
+			definition: Definition{
+				Name: "http",
+				Type: TypeHTTP,
+				Port: p,
+				Expect: Expect{
+					Body: "ok",
+				},
+			},
+			expectedStatus: check.StatusFailed,
+		},
+	}
+
+	for index, test := range tests {
+		c, err := New(Config{
+			Logger:     microloggertest.New(),
+			Definition: test.definition,
+		})
+		if err != nil {
+			t.Fatalf("%d: unexpected error %#v", index, err)
+		}
+
+		result := c.Check(context.Background(), check.Target{IP: "127.0.0.1"})
+		if result.Status != test.expectedStatus {
+			t.Fatalf("%d: expected status %s got %s with error %q", index, test.expectedStatus, result.Status, result.Error)
+		}
+		if test.expectedHost != nil && result.Details["host"] != test.expectedHost {
+			t.Fatalf("%d: expected host %v got %v", index, test.expectedHost, result.Details["host"])
+		}
+	}
+}
+
+func Test_Definition_New_Invalid(t *testing.T) {
+	tests := []struct {
+		definition Definition
+	}{
+		// test 0 - tcp without port
+		{
+			definition: Definition{Name: "ssh", Type: TypeTCP},
+		},
+		// test 1 - dns without query
+		{
+			definition: Definition{Name: "coredns", Type: TypeDNS},
+		},
+		// test 2 - invalid host template
+		{
+			definition: Definition{Name: "ssh", Type: TypeTCP, Port: 22, Host: "{{.IP"},
+		},
+	}
+
+	for index, test := range tests {
+		_, err := New(Config{
+			Logger:     microloggertest.New(),
+			Definition: test.definition,
+		})
+		if !IsInvalidConfig(err) {
+			t.Fatalf("%d: expected invalid config error got %#v", index, err)
+		}
+	}
+}
+
+func Test_Definition_ICMP_NoTCPFallback(t *testing.T) {
+	c, err := New(Config{
+		Logger:     microloggertest.New(),
+		Definition: Definition{Name: "icmp", Type: TypeICMP},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(c.Checker.(*ping.Checker).Settings())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var settings map[string]interface{}
+	err = json.Unmarshal(b, &settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings["mode"] != string(ping.ModeAuto) {
+		t.Fatalf("expected mode %s got %v", ping.ModeAuto, settings["mode"])
+	}
+	if _, ok := settings["tcp_port"]; ok {
+		t.Fatalf("expected TCP fallback to be disabled got port %v", settings["tcp_port"])
+	}
+}
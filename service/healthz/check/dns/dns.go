@@ -0,0 +1,184 @@
+// Package dns implements a checker resolving a name using a DNS server running
+// on the KVM, e.g. the cluster DNS of the guest.
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check"
+)
+
+const (
+	// DefaultPort is the port of the DNS server by default.
+	DefaultPort = 53
+	// DefaultTimeout is the time after which resolving is canceled by default.
+	DefaultTimeout = 4 * time.Second
+)
+
+// Config represents the configuration used to create a dns checker.
+type Config struct {
+	// Dependencies.
+	Logger micrologger.Logger
+
+	// Settings.
+	Description string
+	// ExpectedAddresses are addresses the name has to resolve to. It is
+	// optional. Empty means any address is accepted.
+	ExpectedAddresses []string
+	Name              string
+	// Port is the port of the DNS server. Defaults to DefaultPort.
+	Port int
+	// Query is the name which is resolved.
+	Query string
+	// Timeout is the time after which resolving is canceled. Defaults to
+	// DefaultTimeout.
+	Timeout time.Duration
+}
+
+// Checker resolves a name using a DNS server running on the KVM.
+type Checker struct {
+	// Dependencies.
+	logger micrologger.Logger
+
+	// Settings.
+	description       string
+	expectedAddresses []string
+	name              string
+	port              int
+	query             string
+	timeout           time.Duration
+}
+
+// New creates a new configured dns checker.
+func New(config Config) (*Checker, error) {
+	// Dependencies.
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.Logger must not be empty")
+	}
+
+	// Settings.
+	if config.Name == "" {
+		return nil, microerror.Maskf(invalidConfigError, "config.Name must not be empty")
+	}
+	if config.Query == "" {
+		return nil, microerror.Maskf(invalidConfigError, "config.Query must not be empty")
+	}
+	if config.Port == 0 {
+		config.Port = DefaultPort
+	}
+	if config.Port < 0 || config.Port > 65535 {
+		return nil, microerror.Maskf(invalidConfigError, "config.Port must be a valid port, got %d", config.Port)
+	}
+	if config.Timeout == 0 {
+		config.Timeout = DefaultTimeout
+	}
+	if config.Timeout < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.Timeout must be positive, got %s", config.Timeout)
+	}
+	for _, a := range config.ExpectedAddresses {
+		if net.ParseIP(a) == nil {
+			return nil, microerror.Maskf(invalidConfigError, "config.ExpectedAddresses must contain IPs, got %q", a)
+		}
+	}
+
+	newChecker := &Checker{
+		// Dependencies.
+		logger: config.Logger,
+
+		// Settings.
+		description:       config.Description,
+		expectedAddresses: config.ExpectedAddresses,
+		name:              config.Name,
+		port:              config.Port,
+		query:             config.Query,
+		timeout:           config.Timeout,
+	}
+
+	return newChecker, nil
+}
+
+func (c *Checker) Check(ctx context.Context, target check.Target) check.Result {
+	result := check.Result{
+		Status: check.StatusFailed,
+	}
+
+	server := net.JoinHostPort(target.IP, strconv.Itoa(c.port))
+
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network string, address string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, server)
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	addresses, err := resolver.LookupHost(ctx, c.query)
+	if err != nil {
+		result.Error = fmt.Sprintf("Failed to resolve %s using %s. %s", c.query, server, err)
+		return result
+	}
+	sort.Strings(addresses)
+
+	result.Details = map[string]interface{}{
+		"addresses": addresses,
+	}
+
+	for _, expected := range c.expectedAddresses {
+		if !containsIP(addresses, expected) {
+			result.Error = fmt.Sprintf("Resolving %s using %s has failed. Expected address %s, got %s.", c.query, server, expected, strings.Join(addresses, ", "))
+			return result
+		}
+	}
+
+	result.Status = check.StatusOK
+	result.Message = fmt.Sprintf("Resolved %s using %s.", c.query, server)
+
+	return result
+}
+
+func (c *Checker) Description() string {
+	return c.description
+}
+
+func (c *Checker) Name() string {
+	return c.name
+}
+
+// Settings returns the effective configuration of the checker.
+func (c *Checker) Settings() interface{} {
+	return struct {
+		Port              int      `json:"port"`
+		Query             string   `json:"query"`
+		ExpectedAddresses []string `json:"expected_addresses,omitempty"`
+		TimeoutMS         float64  `json:"timeout_ms"`
+	}{
+		Port:              c.port,
+		Query:             c.query,
+		ExpectedAddresses: c.expectedAddresses,
+		TimeoutMS:         check.Milliseconds(c.timeout),
+	}
+}
+
+// containsIP tells whether the given IP is one of the given addresses,
+// regardless of how the IPs are written.
+func containsIP(addresses []string, ip string) bool {
+	for _, a := range addresses {
+		if net.ParseIP(a).Equal(net.ParseIP(ip)) {
+			return true
+		}
+	}
+
+	return false
+}
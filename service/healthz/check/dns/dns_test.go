@@ -0,0 +1,180 @@
+package dns
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check"
+)
+
+// serveDNS answers the A queries of the given name with the given address
+// until the given connection is closed. Other names do not exist.
+func serveDNS(conn net.PacketConn, name string, address [4]byte) {
+	b := make([]byte, 512)
+	for {
+		n, addr, err := conn.ReadFrom(b)
+		if err != nil {
+			return
+		}
+
+		var p dnsmessage.Parser
+		header, err := p.Start(b[:n])
+		if err != nil {
+			continue
+		}
+		question, err := p.Question()
+		if err != nil {
+			continue
+		}
+
+		rcode := dnsmessage.RCodeSuccess
+		if question.Name.String() != name {
+			rcode = dnsmessage.RCodeNameError
+		}
+
+		builder := dnsmessage.NewBuilder(nil, dnsmessage.Header{
+			ID:            header.ID,
+			Response:      true,
+			Authoritative: true,
+			RCode:         rcode,
+		})
+		_ = builder.StartQuestions()
+		_ = builder.Question(question)
+		if question.Name.String() == name && question.Type == dnsmessage.TypeA {
+			_ = builder.StartAnswers()
+			_ = builder.AResource(dnsmessage.ResourceHeader{
+				Name:  question.Name,
+				Class: dnsmessage.ClassINET,
+				TTL:   60,
+			}, dnsmessage.AResource{A: address})
+		}
+
+		response, err := builder.Finish()
+		if err != nil {
+			continue
+		}
+		_, _ = conn.WriteTo(response, addr)
+	}
+}
+
+func Test_DNS_Check(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close() // nolint
+	go serveDNS(conn, "kubernetes.default.svc.cluster.local.", [4]byte{172, 31, 0, 1})
+
+	_, port, err := net.SplitHostPort(conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query             string
+		expectedAddresses []string
+		port              int
+		expectedStatus    check.Status
+	}{
+		// test 0 - any address
+		{
+			query:          "kubernetes.default.svc.cluster.local",
+			port:           p,
+			expectedStatus: check.StatusOK,
+		},
+		// test 1 - expected address
+		{
+			query:             "kubernetes.default.svc.cluster.local",
+			expectedAddresses: []string{"172.31.0.1"},
+			port:              p,
+			expectedStatus:    check.StatusOK,
+		},
+		// test 2 - unexpected address
+		{
+			query:             "kubernetes.default.svc.cluster.local",
+			expectedAddresses: []string{"172.31.0.10"},
+			port:              p,
+			expectedStatus:    check.StatusFailed,
+		},
+		// test 3 - unknown name
+		{
+			query:          "kubernetes.default.svc.cluster.invalid",
+			port:           p,
+			expectedStatus: check.StatusFailed,
+		},
+		// test 4 - no DNS server
+		{
+			query:          "kubernetes.default.svc.cluster.local",
+			port:           1,
+			expectedStatus: check.StatusFailed,
+		},
+	}
+
+	for index, test := range tests {
+		c, err := New(Config{
+			Logger: microloggertest.New(),
+
+			ExpectedAddresses: test.expectedAddresses,
+			Name:              "test",
+			Port:              test.port,
+			Query:             test.query,
+			Timeout:           time.Second,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		result := c.Check(context.Background(), check.Target{IP: "127.0.0.1"})
+		if result.Status != test.expectedStatus {
+			t.Fatalf("%d: expected status %s got %s with error %q", index, test.expectedStatus, result.Status, result.Error)
+		}
+		if result.Status == check.StatusOK {
+			addresses, _ := result.Details["addresses"].([]string)
+			if len(addresses) != 1 || addresses[0] != "172.31.0.1" {
+				t.Fatalf("%d: expected addresses [172.31.0.1] got %v", index, result.Details["addresses"])
+			}
+		}
+	}
+}
+
+func Test_DNS_New_Invalid(t *testing.T) {
+	tests := []struct {
+		config Config
+	}{
+		// test 0 - no name
+		{
+			config: Config{Query: "kubernetes.default.svc.cluster.local"},
+		},
+		// test 1 - no query
+		{
+			config: Config{Name: "coredns"},
+		},
+		// test 2 - port out of range
+		{
+			config: Config{Name: "coredns", Query: "kubernetes", Port: 70000},
+		},
+		// test 3 - expected address no IP
+		{
+			config: Config{Name: "coredns", Query: "kubernetes", ExpectedAddresses: []string{"kubernetes"}},
+		},
+	}
+
+	for index, test := range tests {
+		test.config.Logger = microloggertest.New()
+
+		_, err := New(test.config)
+		if !IsInvalidConfig(err) {
+			t.Fatalf("%d: expected invalid config error got %#v", index, err)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package check
+
+import "github.com/giantswarm/microerror"
+
+var alreadyRegisteredError = microerror.New("already registered")
+
+// IsAlreadyRegistered asserts alreadyRegisteredError.
+func IsAlreadyRegistered(err error) bool {
+	return microerror.Cause(err) == alreadyRegisteredError
+}
+
+var invalidConfigError = microerror.New("invalid config")
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
+var notFoundError = microerror.New("not found")
+
+// IsNotFound asserts notFoundError.
+func IsNotFound(err error) bool {
+	return microerror.Cause(err) == notFoundError
+}
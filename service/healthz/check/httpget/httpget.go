@@ -0,0 +1,358 @@
+// Package httpget implements a checker sending HTTP GET requests to a health
+// endpoint of the KVM, e.g. the one of the kubelet or the K8s API.
+package httpget
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check"
+)
+
+const (
+	// config
+	maxBodySize       = 64 * 1024
+	maxIdleConnection = 10
+	maxSnippetSize    = 256
+)
+
+const (
+	// DefaultTimeout is the time after which requests are canceled by default.
+	DefaultTimeout = 4 * time.Second
+)
+
+// Config represents the configuration used to create a httpget checker.
+type Config struct {
+	// Dependencies.
+	Logger micrologger.Logger
+
+	// Settings.
+	Description string
+	// ExpectedBody is the body the endpoint has to respond with, ignoring
+	// leading and trailing whitespace. It is optional. Empty means any body is
+	// accepted.
+	ExpectedBody string
+	// ExpectedStatusCodes are the status codes the endpoint has to respond
+	// with. Defaults to 200.
+	ExpectedStatusCodes []int
+	// Host is the host the requests are sent to. Defaults to the IP of the
+	// KVM the check is performed against.
+	Host string
+	Name string
+	// Path is the path of the health endpoint and must start with /.
+	Path   string
+	Port   int
+	Scheme string
+	// LatencyWarning is the time from which on a successful check reports a
+	// warning that the endpoint is slow, which degrades the health. Zero
+	// disables the warning.
+	LatencyWarning time.Duration
+	// Timeout is the time after which requests are canceled. Defaults to
+	// DefaultTimeout.
+	Timeout time.Duration
+	TLS     TLSConfig
+	// TokenFile is the path of a file holding a bearer token sent with every
+	// request. The file is read again when it changes. It is optional. With
+	// the https scheme the token is only sent to verified endpoints, see
+	// TLSConfig.CAFile.
+	TokenFile string
+	// Verbose adds the verbose query parameter to the request and reports the
+	// per-component [+] and [-] lines of the response as sub checks, as
+	// supported by the /livez and /readyz endpoints of the K8s API.
+	Verbose bool
+}
+
+// Checker sends HTTP GET requests to a health endpoint of the KVM.
+type Checker struct {
+	// Dependencies.
+	client *http.Client
+	logger micrologger.Logger
+	tr     *http.Transport
+
+	// Settings.
+	description         string
+	expectedBody        string
+	expectedStatusCodes []int
+	expiryWarning       time.Duration
+	host                string
+	latencyWarning      time.Duration
+	name                string
+	path                string
+	port                int
+	scheme              string
+	settings            settings
+	tokenFile           *tokenFile
+	verbose             bool
+}
+
+// settings is the configuration of the checker as returned by Settings. It
+// only holds the paths of the files used for authentication.
+type settings struct {
+	Host                string  `json:"host,omitempty"`
+	Port                int     `json:"port"`
+	Path                string  `json:"path"`
+	Scheme              string  `json:"scheme"`
+	TimeoutMS           float64 `json:"timeout_ms"`
+	LatencyWarningMS    float64 `json:"latency_warning_ms,omitempty"`
+	ExpectedBody        string  `json:"expected_body,omitempty"`
+	ExpectedStatusCodes []int   `json:"expected_status_codes"`
+	CAFile              string  `json:"ca_file,omitempty"`
+	CertFile            string  `json:"cert_file,omitempty"`
+	ServerName          string  `json:"server_name,omitempty"`
+	TokenFile           string  `json:"token_file,omitempty"`
+	Verbose             bool    `json:"verbose,omitempty"`
+}
+
+// New creates a new configured httpget checker.
+func New(config Config) (*Checker, error) {
+	// Dependencies.
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.Logger must not be empty")
+	}
+
+	// Settings.
+	if config.Name == "" {
+		return nil, microerror.Maskf(invalidConfigError, "config.Name must not be empty")
+	}
+	if !strings.HasPrefix(config.Path, "/") {
+		return nil, microerror.Maskf(invalidConfigError, "config.Path must start with /, got %q", config.Path)
+	}
+	if config.Port <= 0 || config.Port > 65535 {
+		return nil, microerror.Maskf(invalidConfigError, "config.Port must be a valid port, got %d", config.Port)
+	}
+	if len(config.ExpectedStatusCodes) == 0 {
+		config.ExpectedStatusCodes = []int{http.StatusOK}
+	}
+	for _, code := range config.ExpectedStatusCodes {
+		if code < 100 || code > 599 {
+			return nil, microerror.Maskf(invalidConfigError, "config.ExpectedStatusCodes must contain valid status codes, got %d", code)
+		}
+	}
+	if config.Timeout == 0 {
+		config.Timeout = DefaultTimeout
+	}
+	if config.Timeout < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.Timeout must be positive, got %s", config.Timeout)
+	}
+	if config.LatencyWarning < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.LatencyWarning must not be negative, got %s", config.LatencyWarning)
+	}
+	if config.Scheme != "http" && config.Scheme != "https" {
+		return nil, microerror.Maskf(invalidConfigError, "config.Scheme must be %q or %q, got %q", "http", "https", config.Scheme)
+	}
+
+	if config.Scheme == "https" && config.TokenFile != "" && config.TLS.CAFile == "" {
+		return nil, microerror.Maskf(invalidConfigError, "config.TLS.CAFile must be given to send config.TokenFile, the token is only sent to verified endpoints")
+	}
+
+	tlsConfig, err := newTLSClientConfig(config.TLS)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	tr := &http.Transport{
+		TLSClientConfig: tlsConfig,
+		MaxIdleConns:    maxIdleConnection,
+	}
+
+	client := &http.Client{
+		Transport: tr,
+		Timeout:   config.Timeout,
+	}
+
+	newChecker := &Checker{
+		// Dependencies.
+		client: client,
+		logger: config.Logger,
+		tr:     tr,
+
+		// Settings.
+		description:         config.Description,
+		expectedBody:        strings.TrimSpace(config.ExpectedBody),
+		expectedStatusCodes: config.ExpectedStatusCodes,
+		expiryWarning:       config.TLS.ExpiryWarning,
+		host:                config.Host,
+		latencyWarning:      config.LatencyWarning,
+		name:                config.Name,
+		path:                config.Path,
+		port:                config.Port,
+		scheme:              config.Scheme,
+		settings: settings{
+			Host:                config.Host,
+			Port:                config.Port,
+			Path:                config.Path,
+			Scheme:              config.Scheme,
+			TimeoutMS:           check.Milliseconds(config.Timeout),
+			LatencyWarningMS:    check.Milliseconds(config.LatencyWarning),
+			ExpectedBody:        config.ExpectedBody,
+			ExpectedStatusCodes: config.ExpectedStatusCodes,
+			CAFile:              config.TLS.CAFile,
+			CertFile:            config.TLS.CertFile,
+			ServerName:          config.TLS.ServerName,
+			TokenFile:           config.TokenFile,
+			Verbose:             config.Verbose,
+		},
+		verbose: config.Verbose,
+	}
+
+	if config.TokenFile != "" {
+		newChecker.tokenFile = &tokenFile{path: config.TokenFile}
+
+		_, err := newChecker.tokenFile.Token()
+		if err != nil {
+			return nil, microerror.Maskf(invalidConfigError, "config.TokenFile must be a readable token file: %s", err)
+		}
+	}
+
+	return newChecker, nil
+}
+
+func (c *Checker) Check(ctx context.Context, target check.Target) check.Result {
+	start := time.Now()
+	result := check.Result{
+		Status: check.StatusFailed,
+	}
+
+	host := target.IP
+	if c.host != "" {
+		host = c.host
+	}
+
+	u := url.URL{
+		Host:   net.JoinHostPort(host, strconv.Itoa(c.port)),
+		Path:   c.path,
+		Scheme: c.scheme,
+	}
+	if c.verbose {
+		u.RawQuery = "verbose"
+	}
+
+	// be sure to close idle connection after health check is finished
+	defer c.tr.CloseIdleConnections()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		result.Error = fmt.Sprintf("Unable to construct health check request for endpoint %s. %s", u.String(), err)
+		return result
+	}
+
+	// close connection after health check request (the TCP connection gets
+	// closed by deferred c.tr.CloseIdleConnections()).
+	req.Header.Add("Connection", "close")
+
+	if c.tokenFile != nil {
+		token, err := c.tokenFile.Token()
+		if err != nil {
+			result.Error = fmt.Sprintf("Unable to read bearer token for endpoint %s. %s", u.String(), err)
+			return result
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	// send request to http endpoint
+	res, err := c.client.Do(req)
+	if err != nil {
+		result.Error = fmt.Sprintf("Failed to send http request to endpoint %s. %s", u.String(), err)
+		return result
+	}
+	defer res.Body.Close()
+
+	// only a limited part of the body is read, the rest is drained so that
+	// the connection is cleanly finished
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, maxBodySize))
+	_, _ = io.Copy(ioutil.Discard, res.Body)
+
+	result.Details = map[string]interface{}{
+		"status_code": res.StatusCode,
+		"body":        snippet(body),
+	}
+	result.Warning = checkCertExpiry(res.TLS, c.expiryWarning, result.Details)
+	if c.verbose {
+		result.SubChecks = parseVerbose(string(body))
+	}
+
+	if err != nil {
+		result.Error = fmt.Sprintf("Failed to read http response of endpoint %s. %s", u.String(), err)
+		return result
+	}
+	failed := failedSubChecks(result.SubChecks)
+	if !containsInt(c.expectedStatusCodes, res.StatusCode) {
+		result.Error = fmt.Sprintf("Healthcheck for http endpoint %s has failed. Expected status code %s, got %d.", u.String(), formatInts(c.expectedStatusCodes), res.StatusCode)
+		if len(failed) > 0 {
+			result.Error = fmt.Sprintf("%s Failed components: %s.", result.Error, strings.Join(failed, ", "))
+		}
+		return result
+	}
+	if len(failed) > 0 {
+		result.Error = fmt.Sprintf("Healthcheck for http endpoint %s has failed. Failed components: %s.", u.String(), strings.Join(failed, ", "))
+		return result
+	}
+	if c.expectedBody != "" && strings.TrimSpace(string(body)) != c.expectedBody {
+		result.Error = fmt.Sprintf("Healthcheck for http endpoint %s has failed. Expected body %q, got %q.", u.String(), c.expectedBody, snippet(body))
+		return result
+	}
+
+	result.Status = check.StatusOK
+	result.Message = fmt.Sprintf("Healthcheck for http endpoint %s has been successful.", u.String())
+
+	latency := time.Since(start)
+	if c.latencyWarning > 0 && latency >= c.latencyWarning {
+		slow := fmt.Sprintf("Endpoint responded in %s, which is slower than %s.", latency.Round(time.Millisecond), c.latencyWarning)
+		result.Warning = strings.TrimSpace(fmt.Sprintf("%s %s", result.Warning, slow))
+	}
+
+	return result
+}
+
+func (c *Checker) Description() string {
+	return c.description
+}
+
+func (c *Checker) Name() string {
+	return c.name
+}
+
+// Settings returns the effective configuration of the checker.
+func (c *Checker) Settings() interface{} {
+	return c.settings
+}
+
+func containsInt(list []int, i int) bool {
+	for _, l := range list {
+		if l == i {
+			return true
+		}
+	}
+
+	return false
+}
+
+func formatInts(list []int) string {
+	var s []string
+	for _, i := range list {
+		s = append(s, strconv.Itoa(i))
+	}
+
+	return strings.Join(s, " or ")
+}
+
+// snippet returns the beginning of the given body for reporting.
+func snippet(body []byte) string {
+	if len(body) > maxSnippetSize {
+		return string(body[:maxSnippetSize]) + "..."
+	}
+
+	return string(body)
+}
@@ -0,0 +1,462 @@
+package httpget
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check"
+)
+
+func Test_HTTPGet_Check(t *testing.T) {
+	tests := []struct {
+		statusCode          int
+		body                string
+		expectedBody        string
+		expectedStatusCodes []int
+		expectedStatus      check.Status
+	}{
+		// test 0 - healthy endpoint
+		{
+			statusCode:     http.StatusOK,
+			body:           "ok",
+			expectedStatus: check.StatusOK,
+		},
+		// test 1 - endpoint answers but reports itself unhealthy
+		{
+			statusCode:     http.StatusInternalServerError,
+			body:           "[-]etcd failed",
+			expectedStatus: check.StatusFailed,
+		},
+		// test 2 - unexpected body
+		{
+			statusCode:     http.StatusOK,
+			body:           "not ok",
+			expectedBody:   "ok",
+			expectedStatus: check.StatusFailed,
+		},
+		// test 3 - expected body with trailing newline
+		{
+			statusCode:     http.StatusOK,
+			body:           "ok\n",
+			expectedBody:   "ok",
+			expectedStatus: check.StatusOK,
+		},
+		// test 4 - additional expected status code
+		{
+			statusCode:          http.StatusUnauthorized,
+			expectedStatusCodes: []int{http.StatusOK, http.StatusUnauthorized},
+			expectedStatus:      check.StatusOK,
+		},
+	}
+
+	for index, test := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(test.statusCode)
+			_, _ = w.Write([]byte(test.body))
+		}))
+
+		host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := strconv.Atoi(port)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c, err := New(Config{
+			Logger: microloggertest.New(),
+
+			ExpectedBody:        test.expectedBody,
+			ExpectedStatusCodes: test.expectedStatusCodes,
+			Name:                "test",
+			Path:                "/healthz",
+			Port:                p,
+			Scheme:              "http",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		result := c.Check(context.Background(), check.Target{IP: host})
+		server.Close()
+
+		if result.Status != test.expectedStatus {
+			t.Fatalf("%d: expected status %s got %s with error %q", index, test.expectedStatus, result.Status, result.Error)
+		}
+		if result.Details["status_code"] != test.statusCode {
+			t.Fatalf("%d: expected status code %d got %v", index, test.statusCode, result.Details["status_code"])
+		}
+	}
+}
+
+func Test_HTTPGet_LatencyWarning(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		latencyWarning  time.Duration
+		expectedWarning bool
+	}{
+		// test 0 - disabled
+		{
+			latencyWarning:  0,
+			expectedWarning: false,
+		},
+		// test 1 - fast enough
+		{
+			latencyWarning:  time.Second,
+			expectedWarning: false,
+		},
+		// test 2 - slow but successful
+		{
+			latencyWarning:  50 * time.Millisecond,
+			expectedWarning: true,
+		},
+	}
+
+	for index, test := range tests {
+		c, err := New(Config{
+			Logger: microloggertest.New(),
+
+			LatencyWarning: test.latencyWarning,
+			Name:           "test",
+			Path:           "/healthz",
+			Port:           p,
+			Scheme:         "http",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		result := c.Check(context.Background(), check.Target{IP: host})
+		if result.Status != check.StatusOK {
+			t.Fatalf("%d: expected status %s got %s with error %q", index, check.StatusOK, result.Status, result.Error)
+		}
+		if (result.Warning != "") != test.expectedWarning {
+			t.Fatalf("%d: expected warning %t got %q", index, test.expectedWarning, result.Warning)
+		}
+	}
+}
+
+func Test_HTTPGet_TLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	err = ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		tls             TLSConfig
+		expectedStatus  check.Status
+		expectedWarning bool
+	}{
+		// test 0 - certificate not verified
+		{
+			tls:            TLSConfig{},
+			expectedStatus: check.StatusOK,
+		},
+		// test 1 - certificate verified against the CA for the IP
+		{
+			tls: TLSConfig{
+				CAFile: caFile,
+			},
+			expectedStatus: check.StatusOK,
+		},
+		// test 2 - certificate verified against the CA for the server name
+		{
+			tls: TLSConfig{
+				CAFile:     caFile,
+				ServerName: "example.com",
+			},
+			expectedStatus: check.StatusOK,
+		},
+		// test 3 - certificate not valid for the server name
+		{
+			tls: TLSConfig{
+				CAFile:     caFile,
+				ServerName: "kubernetes.default.svc",
+			},
+			expectedStatus: check.StatusFailed,
+		},
+		// test 4 - certificate close to expiry
+		{
+			tls: TLSConfig{
+				ExpiryWarning: 200 * 365 * 24 * time.Hour,
+			},
+			expectedStatus:  check.StatusOK,
+			expectedWarning: true,
+		},
+	}
+
+	for index, test := range tests {
+		c, err := New(Config{
+			Logger: microloggertest.New(),
+
+			Name:   "test",
+			Path:   "/healthz",
+			Port:   p,
+			Scheme: "https",
+			TLS:    test.tls,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		result := c.Check(context.Background(), check.Target{IP: host})
+
+		if result.Status != test.expectedStatus {
+			t.Fatalf("%d: expected status %s got %s with error %q", index, test.expectedStatus, result.Status, result.Error)
+		}
+		if (result.Warning != "") != test.expectedWarning {
+			t.Fatalf("%d: expected warning %t got %q", index, test.expectedWarning, result.Warning)
+		}
+		if result.Status == check.StatusOK && result.Details["cert_not_after"] == nil {
+			t.Fatalf("%d: expected certificate expiry to be reported", index)
+		}
+	}
+}
+
+func Test_HTTPGet_TokenCA(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	err = ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a CA the certificate of the server is not signed by
+	otherCAFile := filepath.Join(dir, "other-ca.pem")
+	{
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		template := &x509.Certificate{
+			SerialNumber:          big.NewInt(1),
+			Subject:               pkix.Name{CommonName: "other"},
+			NotBefore:             time.Now(),
+			NotAfter:              time.Now().Add(time.Hour),
+			IsCA:                  true,
+			BasicConstraintsValid: true,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ioutil.WriteFile(otherCAFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	tokenFile := filepath.Join(dir, "token")
+	err = ioutil.WriteFile(tokenFile, []byte("secret\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		caFile         string
+		expectedErr    func(error) bool
+		expectedStatus check.Status
+	}{
+		// test 0 - verified against the given CA
+		{
+			caFile:         caFile,
+			expectedStatus: check.StatusOK,
+		},
+		// test 1 - the CA does not match, the token is not sent
+		{
+			caFile:         otherCAFile,
+			expectedStatus: check.StatusFailed,
+		},
+		// test 2 - no CA, there is no default for the CA of the KVM
+		{
+			caFile:      "",
+			expectedErr: IsInvalidConfig,
+		},
+	}
+
+	for index, test := range tests {
+		c, err := New(Config{
+			Logger: microloggertest.New(),
+
+			Name:   "test",
+			Path:   "/healthz",
+			Port:   p,
+			Scheme: "https",
+			TLS: TLSConfig{
+				CAFile: test.caFile,
+			},
+			TokenFile: tokenFile,
+		})
+		if test.expectedErr != nil {
+			if !test.expectedErr(err) {
+				t.Fatalf("%d: unexpected error %#v", index, err)
+			}
+			continue
+		} else if err != nil {
+			t.Fatalf("%d: unexpected error %#v", index, err)
+		}
+
+		result := c.Check(context.Background(), check.Target{IP: host})
+		if result.Status != test.expectedStatus {
+			t.Fatalf("%d: expected status %s got %s with error %q", index, test.expectedStatus, result.Status, result.Error)
+		}
+	}
+}
+
+func Test_HTTPGet_TokenAndVerbose(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if _, ok := r.URL.Query()["verbose"]; !ok {
+			t.Errorf("expected verbose query parameter")
+		}
+		if body != "" && body[1] == '-' {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	err = ioutil.WriteFile(tokenFile, []byte("wrong\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := New(Config{
+		Logger: microloggertest.New(),
+
+		Name:      "test",
+		Path:      "/readyz",
+		Port:      p,
+		Scheme:    "http",
+		TokenFile: tokenFile,
+		Verbose:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		token             string
+		body              string
+		expectedStatus    check.Status
+		expectedSubChecks int
+	}{
+		// test 0 - wrong token
+		{
+			token:          "wrong",
+			expectedStatus: check.StatusFailed,
+		},
+		// test 1 - token changed, healthy components
+		{
+			token:             "secret",
+			body:              "[+]ping ok\n[+]etcd ok\nreadyz check passed\n",
+			expectedStatus:    check.StatusOK,
+			expectedSubChecks: 2,
+		},
+		// test 2 - failed component
+		{
+			token:             "secret",
+			body:              "[-]etcd failed: reason withheld\n[+]ping ok\nreadyz check failed\n",
+			expectedStatus:    check.StatusFailed,
+			expectedSubChecks: 2,
+		},
+	}
+
+	for index, test := range tests {
+		// the modification time is moved so that the change is noticed
+		// regardless of the resolution of the file system
+		err = ioutil.WriteFile(tokenFile, []byte(test.token+"\n"), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		modTime := time.Now().Add(time.Duration(index) * time.Second)
+		err = os.Chtimes(tokenFile, modTime, modTime)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body = test.body
+
+		result := c.Check(context.Background(), check.Target{IP: host})
+
+		if result.Status != test.expectedStatus {
+			t.Fatalf("%d: expected status %s got %s with error %q", index, test.expectedStatus, result.Status, result.Error)
+		}
+		if len(result.SubChecks) != test.expectedSubChecks {
+			t.Fatalf("%d: expected %d sub checks got %d", index, test.expectedSubChecks, len(result.SubChecks))
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package httpget
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/giantswarm/microerror"
+)
+
+// TLSConfig configures how the checker verifies the endpoint and
+// authenticates against it. It is only used with the https scheme.
+type TLSConfig struct {
+	// CAFile is the path of a PEM encoded CA bundle the certificate of the
+	// endpoint is verified against, e.g. the cluster CA. The certificate is
+	// not verified in case it is empty. It must be given to send a bearer
+	// token. There is no default since the CA of the cluster the pod runs in
+	// does not verify the K8s API of the KVM.
+	CAFile string
+	// CertFile and KeyFile are the paths of the PEM encoded client certificate
+	// and key used to authenticate against the endpoint. They are optional but
+	// must be given together. They are read on every connection, so that
+	// rotated certificates are picked up.
+	CertFile string
+	KeyFile  string
+	// ExpiryWarning is the time before the certificate of the endpoint expires
+	// from which on the check reports a warning. Zero disables the warning.
+	ExpiryWarning time.Duration
+	// ServerName is the name the certificate of the endpoint is verified for
+	// and sent with SNI. Defaults to the IP of the KVM.
+	ServerName string
+}
+
+// newTLSClientConfig creates the TLS client config for the given TLS config.
+// The files are validated up front so that misconfigurations show at startup.
+func newTLSClientConfig(config TLSConfig) (*tls.Config, error) {
+	if (config.CertFile == "") != (config.KeyFile == "") {
+		return nil, microerror.Maskf(invalidConfigError, "config.TLS.CertFile and config.TLS.KeyFile must be given together")
+	}
+	if config.ExpiryWarning < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.TLS.ExpiryWarning must not be negative, got %s", config.ExpiryWarning)
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName: config.ServerName,
+	}
+
+	if config.CAFile == "" {
+		tlsConfig.InsecureSkipVerify = true // nolint
+	} else {
+		b, err := ioutil.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, microerror.Maskf(invalidConfigError, "config.TLS.CAFile must be readable: %s", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, microerror.Maskf(invalidConfigError, "config.TLS.CAFile must contain PEM encoded certificates, got none in %q", config.CAFile)
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	if config.CertFile != "" {
+		_, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, microerror.Maskf(invalidConfigError, "config.TLS.CertFile and config.TLS.KeyFile must be a valid key pair: %s", err)
+		}
+
+		tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+
+			return &cert, nil
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+// checkCertExpiry reports when the certificate the endpoint presented expires
+// and returns a warning in case it expires within the given duration.
+func checkCertExpiry(state *tls.ConnectionState, warning time.Duration, details map[string]interface{}) string {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return ""
+	}
+
+	cert := state.PeerCertificates[0]
+	expiresIn := time.Until(cert.NotAfter)
+
+	details["cert_subject"] = cert.Subject.String()
+	details["cert_not_after"] = cert.NotAfter.UTC()
+	details["cert_expires_in_s"] = int64(expiresIn.Seconds())
+
+	if warning > 0 && expiresIn < warning {
+		return fmt.Sprintf("Certificate %q expires at %s, in less than %s.", cert.Subject.String(), cert.NotAfter.UTC().Format(time.RFC3339), warning)
+	}
+
+	return ""
+}
@@ -0,0 +1,52 @@
+package httpget
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/giantswarm/microerror"
+)
+
+// tokenFile reads a bearer token from a file. The file is read again as soon
+// as it changes, so that rotated tokens are picked up.
+type tokenFile struct {
+	path string
+
+	mutex   sync.Mutex
+	modTime time.Time
+	size    int64
+	token   string
+}
+
+// Token returns the current token of the file.
+func (f *tokenFile) Token() (string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+	if f.token != "" && info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return f.token, nil
+	}
+
+	b, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return "", microerror.Newf("token file %q must not be empty", f.path)
+	}
+
+	f.modTime = info.ModTime()
+	f.size = info.Size()
+	f.token = token
+
+	return f.token, nil
+}
@@ -0,0 +1,63 @@
+package httpget
+
+import (
+	"strings"
+
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check"
+)
+
+const (
+	verboseFailedPrefix = "[-]"
+	verboseOKPrefix     = "[+]"
+)
+
+// parseVerbose parses the per-component lines of a verbose K8s API /livez or
+// /readyz response, e.g.
+//
+//	[+]ping ok
+//	[-]etcd failed: reason withheld
+//	readyz check failed
+//
+// Lines not describing a component are ignored.
+func parseVerbose(body string) []check.SubResult {
+	var results []check.SubResult
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+
+		var status check.Status
+		switch {
+		case strings.HasPrefix(line, verboseOKPrefix):
+			status = check.StatusOK
+		case strings.HasPrefix(line, verboseFailedPrefix):
+			status = check.StatusFailed
+		default:
+			continue
+		}
+
+		line = line[len(verboseOKPrefix):]
+		name, message := line, ""
+		if i := strings.IndexAny(line, " \t"); i >= 0 {
+			name, message = line[:i], strings.TrimSpace(line[i+1:])
+		}
+
+		results = append(results, check.SubResult{
+			Name:    name,
+			Status:  status,
+			Message: message,
+		})
+	}
+
+	return results
+}
+
+// failedSubChecks returns the names of the failed sub checks.
+func failedSubChecks(results []check.SubResult) []string {
+	var names []string
+	for _, r := range results {
+		if r.Failed() {
+			names = append(names, r.Name)
+		}
+	}
+
+	return names
+}
@@ -0,0 +1,44 @@
+package httpget
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check"
+)
+
+func Test_HTTPGet_ParseVerbose(t *testing.T) {
+	tests := []struct {
+		body            string
+		expectedResults []check.SubResult
+	}{
+		// test 0 - no verbose response
+		{
+			body:            "ok",
+			expectedResults: nil,
+		},
+		// test 1 - healthy components
+		{
+			body: "[+]ping ok\n[+]poststarthook/start-kube-aggregator-informers ok\nreadyz check passed\n",
+			expectedResults: []check.SubResult{
+				{Name: "ping", Status: check.StatusOK, Message: "ok"},
+				{Name: "poststarthook/start-kube-aggregator-informers", Status: check.StatusOK, Message: "ok"},
+			},
+		},
+		// test 2 - failed component
+		{
+			body: "[+]ping ok\r\n[-]etcd failed: reason withheld\r\nreadyz check failed\r\n",
+			expectedResults: []check.SubResult{
+				{Name: "ping", Status: check.StatusOK, Message: "ok"},
+				{Name: "etcd", Status: check.StatusFailed, Message: "failed: reason withheld"},
+			},
+		},
+	}
+
+	for index, test := range tests {
+		results := parseVerbose(test.body)
+		if !reflect.DeepEqual(results, test.expectedResults) {
+			t.Fatalf("%d: expected %#v got %#v", index, test.expectedResults, results)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package check
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// PrometheusNamespace is the namespace of all metrics of k8s-kvm-health.
+	PrometheusNamespace = "k8s_kvm_health"
+	// PrometheusSubsystem is the subsystem of the metrics about the checks.
+	PrometheusSubsystem = "check"
+)
+
+var (
+	checkTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: PrometheusNamespace,
+			Subsystem: PrometheusSubsystem,
+			Name:      "total",
+			Help:      "Number of checks performed against the KVM, by status.",
+		},
+		[]string{"check", "ip", "status"},
+	)
+	checkDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: PrometheusNamespace,
+			Subsystem: PrometheusSubsystem,
+			Name:      "duration_seconds",
+			Help:      "Time it took to perform a check against the KVM, e.g. the round-trip of the kubelet or K8s API request.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 13),
+		},
+		[]string{"check", "ip"},
+	)
+	checkLastSuccess = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: PrometheusNamespace,
+			Subsystem: PrometheusSubsystem,
+			Name:      "last_success_timestamp_seconds",
+			Help:      "Unix time of the last successful check against the KVM.",
+		},
+		[]string{"check", "ip"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(checkTotal)
+	prometheus.MustRegister(checkDuration)
+	prometheus.MustRegister(checkLastSuccess)
+}
+
+// observe records the given result in the metrics.
+func observe(r Result) {
+	checkTotal.WithLabelValues(r.Name, r.Target, string(r.Status)).Inc()
+	checkDuration.WithLabelValues(r.Name, r.Target).Observe(r.Latency.Seconds())
+	if !r.Failed() {
+		checkLastSuccess.WithLabelValues(r.Name, r.Target).Set(float64(r.Timestamp.Add(r.Latency).Unix()))
+	}
+}
+
+// SetLastSuccess sets the time the check of the given name last succeeded
+// against the given target, e.g. one persisted before a restart.
+func SetLastSuccess(name string, target string, t time.Time) {
+	checkLastSuccess.WithLabelValues(name, target).Set(float64(t.Unix()))
+}
@@ -0,0 +1,40 @@
+package check_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check"
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check/checktest"
+)
+
+func Test_Metrics(t *testing.T) {
+	for _, status := range []check.Status{check.StatusOK, check.StatusFailed} {
+		c := checktest.New(checktest.Config{Name: "metrics", Status: status})
+		check.Run(context.Background(), c, check.Target{IP: "172.23.3.66"})
+	}
+
+	// microkit serves the default registry at /metrics the same way
+	w := httptest.NewRecorder()
+	promhttp.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, series := range []string{
+		`k8s_kvm_health_check_total{check="metrics",ip="172.23.3.66",status="ok"} 1`,
+		`k8s_kvm_health_check_total{check="metrics",ip="172.23.3.66",status="failed"} 1`,
+		`k8s_kvm_health_check_duration_seconds_count{check="metrics",ip="172.23.3.66"} 2`,
+		`k8s_kvm_health_check_last_success_timestamp_seconds{check="metrics",ip="172.23.3.66"}`,
+	} {
+		if !strings.Contains(string(body), series) {
+			t.Fatalf("expected series %s in\n%s", series, body)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package ping
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/icmp"
+
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check"
+)
+
+// Mode selects how the KVM is pinged.
+type Mode string
+
+const (
+	// ModeAuto uses unprivileged ICMP sockets in case the
+	// net.ipv4.ping_group_range sysctl allows them, privileged ICMP sockets in
+	// case CAP_NET_RAW is granted and a TCP connect probe otherwise.
+	ModeAuto Mode = "auto"
+	// ModePrivileged uses raw ICMP sockets, which require CAP_NET_RAW.
+	ModePrivileged Mode = "privileged"
+	// ModeUnprivileged uses datagram ICMP sockets, which require the group of
+	// the process to be within the net.ipv4.ping_group_range sysctl.
+	ModeUnprivileged Mode = "unprivileged"
+	// ModeTCP only uses the TCP connect probe.
+	ModeTCP Mode = "tcp"
+)
+
+// Modes lists all supported modes.
+var Modes = []Mode{
+	ModeAuto,
+	ModePrivileged,
+	ModeUnprivileged,
+	ModeTCP,
+}
+
+// Method is the way the KVM was actually pinged. It is reported in the
+// details of the result.
+type Method string
+
+const (
+	MethodICMPPrivileged   Method = "icmp-privileged"
+	MethodICMPUnprivileged Method = "icmp-unprivileged"
+	MethodTCP              Method = "tcp"
+)
+
+// method selects how to ping the given IP according to the mode. In case the
+// ICMP sockets of the mode cannot be opened, it falls back to the TCP connect
+// probe.
+func (c *Checker) method(ip net.IP) Method {
+	ipv4 := ip.To4() != nil
+
+	switch c.mode {
+	case ModeAuto:
+		if canListen(false, ipv4) {
+			return MethodICMPUnprivileged
+		}
+		if canListen(true, ipv4) {
+			return MethodICMPPrivileged
+		}
+	case ModePrivileged:
+		if canListen(true, ipv4) {
+			return MethodICMPPrivileged
+		}
+	case ModeUnprivileged:
+		if canListen(false, ipv4) {
+			return MethodICMPUnprivileged
+		}
+	}
+
+	return MethodTCP
+}
+
+// canListen tells whether an ICMP socket of the given kind can be opened. It
+// fails for privileged sockets without CAP_NET_RAW and for unprivileged ones
+// in case the group of the process is not within the
+// net.ipv4.ping_group_range sysctl.
+func canListen(privileged bool, ipv4 bool) bool {
+	var network, address string
+	switch {
+	case privileged && ipv4:
+		network, address = "ip4:icmp", "0.0.0.0"
+	case privileged:
+		network, address = "ip6:ipv6-icmp", "::"
+	case ipv4:
+		network, address = "udp4", "0.0.0.0"
+	default:
+		network, address = "udp6", "::"
+	}
+
+	conn, err := icmp.ListenPacket(network, address)
+	if err != nil {
+		return false
+	}
+	_ = conn.Close()
+
+	return true
+}
+
+// checkTCP probes the KVM by connecting to the configured TCP port. The KVM
+// counts as reachable in case the connection is established or refused, since
+// both require the KVM to answer.
+func (c *Checker) checkTCP(ctx context.Context, target check.Target, result check.Result) check.Result {
+	if c.tcpPort == 0 {
+		result.Error = fmt.Sprintf("Healthcheck for KVM has failed. ICMP is not possible in mode %s and the TCP fallback is disabled.", c.mode)
+		return result
+	}
+
+	address := net.JoinHostPort(target.IP, strconv.Itoa(c.tcpPort))
+	result.Details["tcp_port"] = c.tcpPort
+
+	dialer := net.Dialer{
+		Timeout: c.timeout,
+	}
+
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	rtt := time.Since(start)
+
+	if err == nil {
+		_ = conn.Close()
+	} else if !isConnectionRefused(err) {
+		result.Error = fmt.Sprintf("Healthcheck for KVM has failed. KVM is not responding on %s%s. %s", address, networkInfo(target), err)
+		return result
+	}
+
+	result.Details["rtt_ms"] = check.Milliseconds(rtt)
+	result.Status = check.StatusOK
+	result.Message = fmt.Sprintf("Healthcheck for KVM has been successful. KVM is live and responding. on %s.", address)
+
+	return result
+}
+
+func isConnectionRefused(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
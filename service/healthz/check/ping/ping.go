@@ -0,0 +1,391 @@
+// Package ping implements a checker pinging the KVM.
+package ping
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sparrc/go-ping"
+
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check"
+)
+
+const (
+	// Description describes which functionality this checker ensures.
+	Description = "Ensure KVM is responding to ping."
+	// Name is the identifier of the checker.
+	Name = "ping"
+
+	// DefaultCount is the number of packets sent by default.
+	DefaultCount = 1
+	// DefaultInterval is the time between two packets by default.
+	DefaultInterval = 1 * time.Second
+	// DefaultTimeout is the time waited for the reply to the last packet by
+	// default.
+	DefaultTimeout = 1 * time.Second
+	// DefaultSize is the size of the payload of the packets by default.
+	DefaultSize = minSize
+	// DefaultMode is the mode used by default.
+	DefaultMode = ModeAuto
+	// DefaultTCPPort is the port the TCP connect probe connects to by default.
+	DefaultTCPPort = 22
+
+	// minSize is the size of the timestamp go-ping sends in every packet.
+	minSize = 8
+	// maxPayload is the maximum size of the payload of an ICMP echo request,
+	// i.e. the maximum size of an IPv4 packet minus its header and the ICMP
+	// header.
+	maxPayload = 65535 - 20 - 8
+	// maxSize is the largest size whose packets fit into maxPayload. go-ping
+	// sends the payload JSON encoded together with a random tracker, i.e.
+	// base64 encoded plus up to 42 bytes. Larger packets fail to be sent,
+	// which go-ping ignores, so that every packet would be reported as lost.
+	maxSize = (maxPayload - 42) / 4 * 3
+)
+
+var (
+	rtt = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: check.PrometheusNamespace,
+			Subsystem: "icmp",
+			Name:      "rtt_seconds",
+			Help:      "Round-trip time of the ICMP echo requests sent to the KVM.",
+			Buckets:   prometheus.ExponentialBuckets(0.0001, 2, 15),
+		},
+		[]string{"ip"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(rtt)
+}
+
+// Config represents the configuration used to create a ping checker.
+type Config struct {
+	// Dependencies.
+	Logger micrologger.Logger
+
+	// Settings.
+	// Name and Description identify the checker. They default to Name and
+	// Description, so that only additional ping checkers have to set them.
+	Name        string
+	Description string
+	// Count is the number of packets sent. Defaults to DefaultCount.
+	Count int
+	// Interval is the time between two packets. Defaults to DefaultInterval.
+	Interval time.Duration
+	// Timeout is the time after which pinging stops, regardless of how many
+	// packets were received. Defaults to the time it takes to send all
+	// packets plus DefaultTimeout.
+	Timeout time.Duration
+	// Size is the size of the payload of the packets in bytes. Defaults to
+	// DefaultSize.
+	Size int
+	// Mode selects how the KVM is pinged. Defaults to DefaultMode.
+	Mode Mode
+	// TCPPort is the port the TCP connect probe connects to in case ICMP is
+	// not possible. Defaults to DefaultTCPPort. A negative port disables the
+	// TCP connect probe.
+	TCPPort int
+
+	// MaxLoss is the percentage of lost packets up to which the check
+	// succeeds. The check always fails in case no packet was received.
+	MaxLoss float64
+	// MaxAvgRTT is the average round-trip time up to which the check
+	// succeeds. Zero disables the threshold.
+	MaxAvgRTT time.Duration
+	// MaxP99RTT is the 99th percentile of the round-trip times up to which the
+	// check succeeds. Zero disables the threshold.
+	MaxP99RTT time.Duration
+}
+
+// Checker pings the KVM.
+type Checker struct {
+	// Dependencies.
+	logger micrologger.Logger
+
+	// Settings.
+	name        string
+	description string
+	count       int
+	interval    time.Duration
+	timeout     time.Duration
+	size        int
+	mode        Mode
+	tcpPort     int
+	maxLoss     float64
+	maxAvgRTT   time.Duration
+	maxP99RTT   time.Duration
+}
+
+// New creates a new configured ping checker.
+func New(config Config) (*Checker, error) {
+	// Dependencies.
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.Logger must not be empty")
+	}
+
+	// Settings.
+	if config.Name == "" {
+		config.Name = Name
+	}
+	if config.Description == "" {
+		config.Description = Description
+	}
+	if config.Count == 0 {
+		config.Count = DefaultCount
+	}
+	if config.Interval == 0 {
+		config.Interval = DefaultInterval
+	}
+	if config.Timeout == 0 && config.Count > 0 {
+		config.Timeout = time.Duration(config.Count-1)*config.Interval + DefaultTimeout
+	}
+	if config.Size == 0 {
+		config.Size = DefaultSize
+	}
+	if config.Mode == "" {
+		config.Mode = DefaultMode
+	}
+	if config.TCPPort == 0 {
+		config.TCPPort = DefaultTCPPort
+	}
+	if config.Count < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.Count must be positive, got %d", config.Count)
+	}
+	if config.Interval < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.Interval must not be negative, got %s", config.Interval)
+	}
+	if config.Timeout <= 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.Timeout must be positive, got %s", config.Timeout)
+	}
+	if config.Size < minSize || config.Size > maxSize {
+		return nil, microerror.Maskf(invalidConfigError, "config.Size must be between %d and %d, got %d", minSize, maxSize, config.Size)
+	}
+	if !isValidMode(config.Mode) {
+		return nil, microerror.Maskf(invalidConfigError, "config.Mode must be one of %v, got %q", Modes, config.Mode)
+	}
+	if config.TCPPort < 0 {
+		config.TCPPort = 0
+	}
+	if config.TCPPort > 65535 {
+		return nil, microerror.Maskf(invalidConfigError, "config.TCPPort must be a valid port, got %d", config.TCPPort)
+	}
+	if config.Mode == ModeTCP && config.TCPPort == 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.TCPPort must not be disabled in mode %s", ModeTCP)
+	}
+	if config.MaxLoss < 0 || config.MaxLoss > 100 {
+		return nil, microerror.Maskf(invalidConfigError, "config.MaxLoss must be between 0 and 100, got %f", config.MaxLoss)
+	}
+	if config.MaxAvgRTT < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.MaxAvgRTT must not be negative")
+	}
+	if config.MaxP99RTT < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.MaxP99RTT must not be negative")
+	}
+
+	newChecker := &Checker{
+		// Dependencies.
+		logger: config.Logger,
+
+		// Settings.
+		name:        config.Name,
+		description: config.Description,
+		count:       config.Count,
+		interval:    config.Interval,
+		timeout:     config.Timeout,
+		size:        config.Size,
+		mode:        config.Mode,
+		tcpPort:     config.TCPPort,
+		maxLoss:     config.MaxLoss,
+		maxAvgRTT:   config.MaxAvgRTT,
+		maxP99RTT:   config.MaxP99RTT,
+	}
+
+	return newChecker, nil
+}
+
+// Check pings the KVM using ICMP, or connects to it using TCP in case ICMP is
+// not possible. The method used is reported in the details of the result.
+func (c *Checker) Check(ctx context.Context, target check.Target) check.Result {
+	result := check.Result{
+		Status: check.StatusFailed,
+	}
+
+	ip := net.ParseIP(target.IP)
+	if ip == nil {
+		result.Error = fmt.Sprintf("Failed to init pinger. Invalid IP %q.", target.IP)
+		return result
+	}
+
+	method := c.method(ip)
+	result.Details = map[string]interface{}{
+		"method": method,
+	}
+
+	if method == MethodTCP {
+		return c.checkTCP(ctx, target, result)
+	}
+
+	return c.checkICMP(ctx, target, method == MethodICMPPrivileged, result)
+}
+
+// checkICMP pings the KVM. The pinger cannot be stopped safely once it runs, so
+// its timeout is cut to the deadline of ctx and the check returns as soon as
+// ctx is done, leaving the pinger to finish on its own.
+func (c *Checker) checkICMP(ctx context.Context, target check.Target, privileged bool, result check.Result) check.Result {
+	timeout := c.timeout
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < timeout {
+		timeout = time.Until(deadline)
+	}
+	if timeout <= 0 || ctx.Err() != nil {
+		result.Error = fmt.Sprintf("Healthcheck for KVM has failed. No time left to ping %s.", target.IP)
+		return result
+	}
+
+	// ping kvm
+	pinger, err := ping.NewPinger(target.IP)
+	if err != nil {
+		result.Error = "Failed to init pinger."
+		return result
+	}
+
+	pinger.Count = c.count
+	pinger.Interval = c.interval
+	pinger.Timeout = timeout
+	pinger.Size = c.size
+	pinger.SetPrivileged(privileged)
+	pinger.OnRecv = func(pkt *ping.Packet) {
+		rtt.WithLabelValues(target.IP).Observe(pkt.Rtt.Seconds())
+	}
+
+	done := make(chan struct{})
+	go func() {
+		pinger.Run()
+		close(done)
+	}()
+
+	select {
+	case <-ctx.Done():
+		result.Error = fmt.Sprintf("Healthcheck for KVM has failed. Pinging %s was canceled. %s", target.IP, ctx.Err())
+		return result
+	case <-done:
+	}
+
+	stats := pinger.Statistics()
+	addDetails(result.Details, stats)
+
+	switch {
+	case stats.PacketsRecv == 0:
+		result.Error = fmt.Sprintf("Healthcheck for KVM has failed. KVM is not responding on  %s%s.", target.IP, networkInfo(target))
+	case stats.PacketLoss > c.maxLoss:
+		result.Error = fmt.Sprintf("Healthcheck for KVM has failed. Lost %.1f%% of %d packets sent to %s, more than %.1f%%.", stats.PacketLoss, stats.PacketsSent, target.IP, c.maxLoss)
+	case c.maxAvgRTT > 0 && stats.AvgRtt > c.maxAvgRTT:
+		result.Error = fmt.Sprintf("Healthcheck for KVM has failed. Average round-trip time to %s is %s, more than %s.", target.IP, stats.AvgRtt, c.maxAvgRTT)
+	case c.maxP99RTT > 0 && p99(stats.Rtts) > c.maxP99RTT:
+		result.Error = fmt.Sprintf("Healthcheck for KVM has failed. 99th percentile of the round-trip times to %s is %s, more than %s.", target.IP, p99(stats.Rtts), c.maxP99RTT)
+	default:
+		// we got positive response
+		result.Status = check.StatusOK
+		result.Message = fmt.Sprintf("Healthcheck for KVM has been successful. KVM is live and responding. on %s.", target.IP)
+	}
+
+	return result
+}
+
+func (c *Checker) Description() string {
+	return c.description
+}
+
+func (c *Checker) Name() string {
+	return c.name
+}
+
+// Settings returns the effective configuration of the checker.
+func (c *Checker) Settings() interface{} {
+	return struct {
+		Count       int     `json:"count"`
+		IntervalMS  float64 `json:"interval_ms"`
+		TimeoutMS   float64 `json:"timeout_ms"`
+		Size        int     `json:"size"`
+		Mode        Mode    `json:"mode"`
+		TCPPort     int     `json:"tcp_port,omitempty"`
+		MaxLoss     float64 `json:"max_loss_percent"`
+		MaxAvgRTTMS float64 `json:"max_avg_rtt_ms,omitempty"`
+		MaxP99RTTMS float64 `json:"max_p99_rtt_ms,omitempty"`
+	}{
+		Count:       c.count,
+		IntervalMS:  check.Milliseconds(c.interval),
+		TimeoutMS:   check.Milliseconds(c.timeout),
+		Size:        c.size,
+		Mode:        c.mode,
+		TCPPort:     c.tcpPort,
+		MaxLoss:     c.maxLoss,
+		MaxAvgRTTMS: check.Milliseconds(c.maxAvgRTT),
+		MaxP99RTTMS: check.Milliseconds(c.maxP99RTT),
+	}
+}
+
+// addDetails reports the statistics of a ping in the details of the result of
+// the check.
+func addDetails(d map[string]interface{}, stats *ping.Statistics) {
+	d["packets_sent"] = stats.PacketsSent
+	d["packets_received"] = stats.PacketsRecv
+
+	// without any packet sent the loss is not a number, which cannot be
+	// encoded as JSON
+	if stats.PacketsSent > 0 {
+		d["loss_percent"] = stats.PacketLoss
+	}
+
+	if len(stats.Rtts) > 0 {
+		d["rtt_min_ms"] = check.Milliseconds(stats.MinRtt)
+		d["rtt_avg_ms"] = check.Milliseconds(stats.AvgRtt)
+		d["rtt_max_ms"] = check.Milliseconds(stats.MaxRtt)
+		d["rtt_stddev_ms"] = check.Milliseconds(stats.StdDevRtt)
+		d["rtt_p99_ms"] = check.Milliseconds(p99(stats.Rtts))
+	}
+}
+
+func isValidMode(mode Mode) bool {
+	for _, m := range Modes {
+		if m == mode {
+			return true
+		}
+	}
+
+	return false
+}
+
+// networkInfo describes the flannel network the target is attached to, if
+// known.
+func networkInfo(target check.Target) string {
+	if target.Network == nil {
+		return ""
+	}
+
+	return fmt.Sprintf(" (flannel network %s, mtu %d)", target.Network, target.MTU)
+}
+
+// p99 returns the 99th percentile of the given round-trip times using the
+// nearest-rank method.
+func p99(rtts []time.Duration) time.Duration {
+	if len(rtts) == 0 {
+		return 0
+	}
+
+	sorted := make([]time.Duration, len(rtts))
+	copy(sorted, rtts)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	rank := int(math.Ceil(0.99 * float64(len(sorted))))
+
+	return sorted[rank-1]
+}
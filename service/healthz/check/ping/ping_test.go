@@ -0,0 +1,159 @@
+package ping
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+	"github.com/sparrc/go-ping"
+
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check"
+)
+
+func Test_Ping_P99(t *testing.T) {
+	ms := func(values ...int) []time.Duration {
+		var rtts []time.Duration
+		for _, v := range values {
+			rtts = append(rtts, time.Duration(v)*time.Millisecond)
+		}
+		return rtts
+	}
+
+	tests := []struct {
+		rtts        []time.Duration
+		expectedP99 time.Duration
+	}{
+		// test 0 - no packets received
+		{
+			rtts:        nil,
+			expectedP99: 0,
+		},
+		// test 1 - single packet
+		{
+			rtts:        ms(5),
+			expectedP99: 5 * time.Millisecond,
+		},
+		// test 2 - few packets, the slowest one counts
+		{
+			rtts:        ms(3, 9, 1, 4),
+			expectedP99: 9 * time.Millisecond,
+		},
+		// test 3 - a single outlier out of 200 packets is ignored
+		{
+			rtts:        append(ms(500), ms(make([]int, 199)...)...),
+			expectedP99: 0,
+		},
+	}
+
+	for index, test := range tests {
+		p := p99(test.rtts)
+		if p != test.expectedP99 {
+			t.Fatalf("%d: expected %s got %s", index, test.expectedP99, p)
+		}
+	}
+}
+
+func Test_Ping_MaxSize(t *testing.T) {
+	tests := []struct {
+		size        int
+		expectedErr func(error) bool
+		expectedFit bool
+	}{
+		// test 0 - the largest size fits into an IP packet
+		{
+			size:        maxSize,
+			expectedErr: nil,
+			expectedFit: true,
+		},
+		// test 1 - larger sizes are rejected, they would not fit
+		{
+			size:        maxSize + 1,
+			expectedErr: IsInvalidConfig,
+			expectedFit: false,
+		},
+	}
+
+	for index, test := range tests {
+		// go-ping sends the payload JSON encoded, the tracker is at most
+		// math.MaxInt64
+		data, err := json.Marshal(ping.IcmpData{Bytes: make([]byte, test.size), Tracker: math.MaxInt64})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fit := len(data) <= maxPayload; fit != test.expectedFit {
+			t.Fatalf("%d: expected payload of %d bytes to fit %t, encoded to %d bytes", index, test.size, test.expectedFit, len(data))
+		}
+
+		_, err = New(Config{
+			Logger: microloggertest.New(),
+			Size:   test.size,
+		})
+		if test.expectedErr != nil {
+			if !test.expectedErr(err) {
+				t.Fatalf("%d: unexpected error %#v", index, err)
+			}
+		} else if err != nil {
+			t.Fatalf("%d: unexpected error %#v", index, err)
+		}
+	}
+}
+
+func Test_Ping_CheckTCP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	openPort := l.Addr().(*net.TCPAddr).Port
+
+	// a port which was just free is most likely refused
+	l2, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refusedPort := l2.Addr().(*net.TCPAddr).Port
+	l2.Close()
+	defer l.Close()
+
+	tests := []struct {
+		ip             string
+		port           int
+		expectedStatus check.Status
+	}{
+		// test 0 - connection established
+		{
+			ip:             "127.0.0.1",
+			port:           openPort,
+			expectedStatus: check.StatusOK,
+		},
+		// test 1 - connection refused, the KVM answered
+		{
+			ip:             "127.0.0.1",
+			port:           refusedPort,
+			expectedStatus: check.StatusOK,
+		},
+	}
+
+	for index, test := range tests {
+		c, err := New(Config{
+			Logger: microloggertest.New(),
+
+			Mode:    ModeTCP,
+			TCPPort: test.port,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		result := c.Check(context.Background(), check.Target{IP: test.ip})
+		if result.Status != test.expectedStatus {
+			t.Fatalf("%d: expected status %s got %s with error %q", index, test.expectedStatus, result.Status, result.Error)
+		}
+		if result.Details["method"] != MethodTCP {
+			t.Fatalf("%d: expected method %s got %v", index, MethodTCP, result.Details["method"])
+		}
+	}
+}
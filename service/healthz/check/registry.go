@@ -0,0 +1,197 @@
+package check
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/giantswarm/microerror"
+)
+
+// Registry holds the known checkers by name. Which of them are performed, and
+// in which order, is selected by name using Enabled.
+type Registry struct {
+	checkers     map[string]Checker
+	dependencies map[string][]string
+	mutex        sync.RWMutex
+	names        []string
+	severities   map[string]Severity
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		checkers:     map[string]Checker{},
+		dependencies: map[string][]string{},
+		severities:   map[string]Severity{},
+	}
+}
+
+// Register adds the given checker to the registry. Names must be unique.
+func (r *Registry) Register(checker Checker) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	name := checker.Name()
+	if name == "" {
+		return microerror.Maskf(invalidConfigError, "checker name must not be empty")
+	}
+	if _, ok := r.checkers[name]; ok {
+		return microerror.Maskf(alreadyRegisteredError, "checker %#q", name)
+	}
+
+	r.checkers[name] = checker
+	r.names = append(r.names, name)
+
+	return nil
+}
+
+// Enabled returns the checkers of the given names in the given order. It
+// fails in case any of the names is not registered.
+func (r *Registry) Enabled(names []string) ([]Checker, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var checkers []Checker
+	for _, name := range names {
+		checker, ok := r.checkers[name]
+		if !ok {
+			return nil, microerror.Maskf(notFoundError, "checker %#q, registered checkers are %v", name, r.names)
+		}
+		checkers = append(checkers, checker)
+	}
+
+	return checkers, nil
+}
+
+// Names returns the names of all registered checkers in the order they were
+// registered.
+func (r *Registry) Names() []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	names := make([]string, len(r.names))
+	copy(names, r.names)
+
+	return names
+}
+
+// AddDependencies makes the checker of the given name depend on the checkers
+// of the given names, in addition to the dependencies it declares itself by
+// implementing Dependent.
+func (r *Registry) AddDependencies(name string, dependencies []string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.checkers[name]; !ok {
+		return microerror.Maskf(notFoundError, "checker %#q, registered checkers are %v", name, r.names)
+	}
+
+	r.dependencies[name] = append(r.dependencies[name], dependencies...)
+
+	return nil
+}
+
+// Dependencies returns the names of the checkers the checker of the given name
+// depends on.
+func (r *Registry) Dependencies(name string) []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.dependenciesOf(name)
+}
+
+func (r *Registry) dependenciesOf(name string) []string {
+	var dependencies []string
+	if dependent, ok := r.checkers[name].(Dependent); ok {
+		dependencies = append(dependencies, dependent.Dependencies()...)
+	}
+	dependencies = append(dependencies, r.dependencies[name]...)
+
+	return dependencies
+}
+
+// SetSeverity sets the severity of the checker of the given name.
+func (r *Registry) SetSeverity(name string, severity Severity) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.checkers[name]; !ok {
+		return microerror.Maskf(notFoundError, "checker %#q, registered checkers are %v", name, r.names)
+	}
+
+	_, err := ParseSeverity(string(severity))
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	r.severities[name] = severity
+
+	return nil
+}
+
+// Severity returns the severity of the checker of the given name. It defaults
+// to SeverityCritical.
+func (r *Registry) Severity(name string) Severity {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	severity, ok := r.severities[name]
+	if !ok {
+		return SeverityCritical
+	}
+
+	return severity
+}
+
+// Validate ensures that the dependencies of all registered checkers are
+// registered as well and that no checker depends on itself, neither directly
+// nor indirectly.
+func (r *Registry) Validate() error {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, name := range r.names {
+		for _, d := range r.dependenciesOf(name) {
+			if _, ok := r.checkers[d]; !ok {
+				return microerror.Maskf(notFoundError, "dependency %#q of checker %#q, registered checkers are %v", d, name, r.names)
+			}
+		}
+	}
+
+	// depth first search, a checker visited again while its dependencies are
+	// still being visited closes a cycle
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := map[string]int{}
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visiting:
+			return microerror.Maskf(invalidConfigError, "checkers must not depend on each other in a cycle, got %s", strings.Join(append(path, name), " -> "))
+		case visited:
+			return nil
+		}
+
+		state[name] = visiting
+		for _, d := range r.dependenciesOf(name) {
+			err := visit(d, append(path, name))
+			if err != nil {
+				return microerror.Mask(err)
+			}
+		}
+		state[name] = visited
+
+		return nil
+	}
+
+	for _, name := range r.names {
+		err := visit(name, nil)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	return nil
+}
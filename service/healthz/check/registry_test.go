@@ -0,0 +1,140 @@
+package check_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check"
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check/checktest"
+)
+
+func Test_Registry_Enabled(t *testing.T) {
+	registry := check.NewRegistry()
+	for _, name := range []string{"ping", "kubelet", "api"} {
+		err := registry.Register(checktest.New(checktest.Config{Name: name}))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		names         []string
+		expectedNames []string
+		expectedErr   func(error) bool
+	}{
+		// test 0 - nothing enabled
+		{
+			names:         nil,
+			expectedNames: nil,
+		},
+		// test 1 - order of the config is kept
+		{
+			names:         []string{"api", "ping"},
+			expectedNames: []string{"api", "ping"},
+		},
+		// test 2 - unknown checker
+		{
+			names:       []string{"ping", "dns"},
+			expectedErr: check.IsNotFound,
+		},
+	}
+
+	for index, test := range tests {
+		checkers, err := registry.Enabled(test.names)
+		if test.expectedErr != nil {
+			if !test.expectedErr(err) {
+				t.Fatalf("%d: unexpected error %#v", index, err)
+			}
+			continue
+		} else if err != nil {
+			t.Fatalf("%d: unexpected error %#v", index, err)
+		}
+
+		var names []string
+		for _, c := range checkers {
+			names = append(names, c.Name())
+		}
+		if !reflect.DeepEqual(names, test.expectedNames) {
+			t.Fatalf("%d: expected %v got %v", index, test.expectedNames, names)
+		}
+	}
+}
+
+func Test_Registry_Register_Duplicate(t *testing.T) {
+	registry := check.NewRegistry()
+
+	err := registry.Register(checktest.New(checktest.Config{Name: "ping"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = registry.Register(checktest.New(checktest.Config{Name: "ping"}))
+	if !check.IsAlreadyRegistered(err) {
+		t.Fatalf("expected already registered error got %#v", err)
+	}
+}
+
+func Test_Registry_Validate(t *testing.T) {
+	tests := []struct {
+		checkers    []check.Checker
+		expectedErr func(error) bool
+	}{
+		// test 0 - no dependencies
+		{
+			checkers: []check.Checker{
+				checktest.New(checktest.Config{Name: "ping"}),
+				checktest.New(checktest.Config{Name: "kubelet"}),
+			},
+		},
+		// test 1 - valid dependencies
+		{
+			checkers: []check.Checker{
+				checktest.New(checktest.Config{Name: "ping"}),
+				checktest.New(checktest.Config{Name: "kubelet", Dependencies: []string{"ping"}}),
+				checktest.New(checktest.Config{Name: "api", Dependencies: []string{"ping", "kubelet"}}),
+			},
+		},
+		// test 2 - unknown dependency
+		{
+			checkers: []check.Checker{
+				checktest.New(checktest.Config{Name: "kubelet", Dependencies: []string{"ping"}}),
+			},
+			expectedErr: check.IsNotFound,
+		},
+		// test 3 - cycle
+		{
+			checkers: []check.Checker{
+				checktest.New(checktest.Config{Name: "ping", Dependencies: []string{"api"}}),
+				checktest.New(checktest.Config{Name: "kubelet", Dependencies: []string{"ping"}}),
+				checktest.New(checktest.Config{Name: "api", Dependencies: []string{"kubelet"}}),
+			},
+			expectedErr: check.IsInvalidConfig,
+		},
+		// test 4 - depends on itself
+		{
+			checkers: []check.Checker{
+				checktest.New(checktest.Config{Name: "ping", Dependencies: []string{"ping"}}),
+			},
+			expectedErr: check.IsInvalidConfig,
+		},
+	}
+
+	for index, test := range tests {
+		registry := check.NewRegistry()
+		for _, c := range test.checkers {
+			err := registry.Register(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		err := registry.Validate()
+		if test.expectedErr != nil {
+			if !test.expectedErr(err) {
+				t.Fatalf("%d: unexpected error %#v", index, err)
+			}
+		} else if err != nil {
+			t.Fatalf("%d: unexpected error %#v", index, err)
+		}
+	}
+}
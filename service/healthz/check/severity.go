@@ -0,0 +1,102 @@
+package check
+
+import (
+	"net/http"
+
+	"github.com/giantswarm/microerror"
+)
+
+// Severity tells how much an unhealthy check affects the overall health.
+type Severity string
+
+const (
+	// SeverityCritical makes the overall health unhealthy in case the check
+	// is unhealthy. It is the default.
+	SeverityCritical Severity = "critical"
+	// SeverityWarning makes the overall health degraded at most.
+	SeverityWarning Severity = "warning"
+	// SeverityInfo does not affect the overall health at all. The check is
+	// only reported.
+	SeverityInfo Severity = "info"
+)
+
+// Severities are all known severities, from the most to the least severe.
+var Severities = []Severity{SeverityCritical, SeverityWarning, SeverityInfo}
+
+// ParseSeverity returns the severity of the given name.
+func ParseSeverity(s string) (Severity, error) {
+	for _, known := range Severities {
+		if Severity(s) == known {
+			return known, nil
+		}
+	}
+
+	return "", microerror.Maskf(invalidConfigError, "severity must be one of %v, got %q", Severities, s)
+}
+
+// Effect returns the state the overall health is at most put into by a check
+// of the severity in the given state. Checks without a state do not affect
+// the overall health.
+func (s Severity) Effect(state State) State {
+	switch {
+	case state == "" || state == StateHealthy:
+		return StateHealthy
+	case s == SeverityInfo:
+		return StateHealthy
+	case s == SeverityWarning:
+		return StateDegraded
+	default:
+		return state
+	}
+}
+
+// WorseState returns the worse of the given states. The empty state is better
+// than any other.
+func WorseState(a State, b State) State {
+	if stateRank(b) > stateRank(a) {
+		return b
+	}
+
+	return a
+}
+
+func stateRank(s State) int {
+	switch s {
+	case StateHealthy:
+		return 1
+	case StateInitializing:
+		return 2
+	case StateDegraded:
+		return 3
+	case StateUnhealthy:
+		return 4
+	default:
+		return 0
+	}
+}
+
+// StatusCodes maps the overall health of a response to the HTTP status code
+// it is served with.
+type StatusCodes map[State]int
+
+// DefaultStatusCodes only fails unhealthy responses, so that degraded and
+// initializing ones still pass the probes.
+func DefaultStatusCodes() StatusCodes {
+	return StatusCodes{
+		StateHealthy:      http.StatusOK,
+		StateInitializing: http.StatusOK,
+		StateDegraded:     http.StatusOK,
+		StateUnhealthy:    http.StatusInternalServerError,
+	}
+}
+
+// Code returns the status code of the given state, falling back to the
+// default status codes for states not configured.
+func (c StatusCodes) Code(state State) int {
+	code, ok := c[state]
+	if !ok {
+		code = DefaultStatusCodes()[state]
+	}
+
+	return code
+}
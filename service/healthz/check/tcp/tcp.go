@@ -0,0 +1,132 @@
+// Package tcp implements a checker connecting to a TCP port of the KVM, e.g.
+// the one of a service running inside the guest.
+package tcp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check"
+)
+
+const (
+	// DefaultTimeout is the time after which connecting is canceled by
+	// default.
+	DefaultTimeout = 4 * time.Second
+)
+
+// Config represents the configuration used to create a tcp checker.
+type Config struct {
+	// Dependencies.
+	Logger micrologger.Logger
+
+	// Settings.
+	Description string
+	Name        string
+	Port        int
+	// Timeout is the time after which connecting is canceled. Defaults to
+	// DefaultTimeout.
+	Timeout time.Duration
+}
+
+// Checker connects to a TCP port of the KVM. Unlike the TCP fallback of the
+// ping checker it fails in case the connection is refused, since it ensures
+// that something listens on the port.
+type Checker struct {
+	// Dependencies.
+	logger micrologger.Logger
+
+	// Settings.
+	description string
+	name        string
+	port        int
+	timeout     time.Duration
+}
+
+// New creates a new configured tcp checker.
+func New(config Config) (*Checker, error) {
+	// Dependencies.
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.Logger must not be empty")
+	}
+
+	// Settings.
+	if config.Name == "" {
+		return nil, microerror.Maskf(invalidConfigError, "config.Name must not be empty")
+	}
+	if config.Port <= 0 || config.Port > 65535 {
+		return nil, microerror.Maskf(invalidConfigError, "config.Port must be a valid port, got %d", config.Port)
+	}
+	if config.Timeout == 0 {
+		config.Timeout = DefaultTimeout
+	}
+	if config.Timeout < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.Timeout must be positive, got %s", config.Timeout)
+	}
+
+	newChecker := &Checker{
+		// Dependencies.
+		logger: config.Logger,
+
+		// Settings.
+		description: config.Description,
+		name:        config.Name,
+		port:        config.Port,
+		timeout:     config.Timeout,
+	}
+
+	return newChecker, nil
+}
+
+func (c *Checker) Check(ctx context.Context, target check.Target) check.Result {
+	result := check.Result{
+		Status: check.StatusFailed,
+	}
+
+	address := net.JoinHostPort(target.IP, strconv.Itoa(c.port))
+
+	dialer := net.Dialer{
+		Timeout: c.timeout,
+	}
+
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		result.Error = fmt.Sprintf("Failed to connect to %s. %s", address, err)
+		return result
+	}
+	_ = conn.Close()
+
+	result.Details = map[string]interface{}{
+		"connect_ms": check.Milliseconds(time.Since(start)),
+	}
+	result.Status = check.StatusOK
+	result.Message = fmt.Sprintf("Connected to %s.", address)
+
+	return result
+}
+
+func (c *Checker) Description() string {
+	return c.description
+}
+
+func (c *Checker) Name() string {
+	return c.name
+}
+
+// Settings returns the effective configuration of the checker.
+func (c *Checker) Settings() interface{} {
+	return struct {
+		Port      int     `json:"port"`
+		TimeoutMS float64 `json:"timeout_ms"`
+	}{
+		Port:      c.port,
+		TimeoutMS: check.Milliseconds(c.timeout),
+	}
+}
@@ -0,0 +1,114 @@
+package tcp
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check"
+)
+
+func Test_TCP_Check(t *testing.T) {
+	tests := []struct {
+		listening      bool
+		canceled       bool
+		expectedStatus check.Status
+	}{
+		// test 0 - something listens on the port
+		{
+			listening:      true,
+			expectedStatus: check.StatusOK,
+		},
+		// test 1 - connection refused
+		{
+			listening:      false,
+			expectedStatus: check.StatusFailed,
+		},
+		// test 2 - canceled before connecting
+		{
+			listening:      true,
+			canceled:       true,
+			expectedStatus: check.StatusFailed,
+		},
+	}
+
+	for index, test := range tests {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !test.listening {
+			_ = listener.Close()
+		}
+
+		_, port, err := net.SplitHostPort(listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := strconv.Atoi(port)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c, err := New(Config{
+			Logger: microloggertest.New(),
+
+			Name: "test",
+			Port: p,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		if test.canceled {
+			cancel()
+		}
+
+		result := c.Check(ctx, check.Target{IP: "127.0.0.1"})
+		cancel()
+		_ = listener.Close()
+
+		if result.Status != test.expectedStatus {
+			t.Fatalf("%d: expected status %s got %s with error %q", index, test.expectedStatus, result.Status, result.Error)
+		}
+		if result.Status == check.StatusOK && result.Details["connect_ms"] == nil {
+			t.Fatalf("%d: expected connect time to be reported", index)
+		}
+	}
+}
+
+func Test_TCP_New_Invalid(t *testing.T) {
+	tests := []struct {
+		config Config
+	}{
+		// test 0 - no name
+		{
+			config: Config{Port: 22},
+		},
+		// test 1 - no port
+		{
+			config: Config{Name: "ssh"},
+		},
+		// test 2 - port out of range
+		{
+			config: Config{Name: "ssh", Port: 70000},
+		},
+		// test 3 - negative timeout
+		{
+			config: Config{Name: "ssh", Port: 22, Timeout: -1},
+		},
+	}
+
+	for index, test := range tests {
+		test.config.Logger = microloggertest.New()
+
+		_, err := New(test.config)
+		if !IsInvalidConfig(err) {
+			t.Fatalf("%d: expected invalid config error got %#v", index, err)
+		}
+	}
+}
@@ -1,31 +1,271 @@
 package healthz
 
 import (
-	"github.com/giantswarm/microendpoint/service/healthz"
+	"time"
+
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger"
 
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check"
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check/definition"
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check/httpget"
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check/ping"
 	"github.com/giantswarm/k8s-kvm-health/service/healthz/kvm"
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/persist"
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/probe"
+)
+
+const (
+	// CheckAPI checks that the K8s API of the KVM responds to HTTPS requests.
+	CheckAPI = "api"
+	// CheckKubelet checks that the kubelet of the KVM responds to HTTP
+	// requests.
+	CheckKubelet = "kubelet"
+	// CheckPing pings the KVM.
+	CheckPing = ping.Name
+
+	livenessDescription  = "Ensure k8s-kvm-health itself is working."
+	livenessName         = "livez"
+	readinessDescription = "Ensure KVM is reachable and its kubelet and K8s API are up."
+	readinessName        = "readyz"
+	startupDescription   = "Ensure KVM has become healthy at least once."
+	startupName          = "startupz"
+
+	apiDescription     = "Ensure the K8s API of the KVM responds to HTTPS requests."
+	apiPort            = 443
+	apiScheme          = "https"
+	defaultPath        = "/healthz"
+	kubeletDescription = "Ensure the kubelet of the KVM responds to HTTP requests."
+	kubeletPort        = 10248
+	kubeletScheme      = "http"
 )
 
+// HTTPConfig configures a check of an HTTP health endpoint of the KVM, see
+// httpget.Config.
+type HTTPConfig struct {
+	ExpectedBody        string
+	ExpectedStatusCodes []int
+	// Host defaults to the IP of the KVM.
+	Host string
+	// LatencyWarning is the time from which on successful checks warn that
+	// the endpoint is slow.
+	LatencyWarning time.Duration
+	// Path defaults to /healthz.
+	Path string
+	// Port and Scheme default to the ones of the kubelet and the K8s API
+	// respectively.
+	Port      int
+	Scheme    string
+	TLS       httpget.TLSConfig
+	TokenFile string
+	Verbose   bool
+}
+
 // Config represents the configuration used to create a healthz service.
 type Config struct {
 	// Dependencies.
-	CheckAPI  bool
-	IPAddress string
-	Logger    micrologger.Logger
+	Logger micrologger.Logger
+
+	// Settings.
+	// API and Kubelet configure the checks of the K8s API and the kubelet.
+	API     HTTPConfig
+	Kubelet HTTPConfig
+	// CheckInterval and CheckJitter configure the background checks, see
+	// kvm.Config.
+	CheckInterval time.Duration
+	CheckJitter   time.Duration
+	// Checks are the names of the enabled checks, in the order they are
+	// performed. The known checks are CheckPing, CheckKubelet and CheckAPI,
+	// plus the ones of the Definitions.
+	Checks []string
+	// Dependencies maps the names of checks to the names of the checks they
+	// depend on, in addition to the dependencies of the Definitions. A check
+	// is skipped in case one of its dependencies failed.
+	Dependencies map[string][]string
+	// Definitions declare checks in addition to the built-in ones, see
+	// definition.Load.
+	Definitions []definition.Definition
+	// HistorySize is the number of check results kept in memory, see
+	// kvm.Config.
+	HistorySize int
+	// LivenessChecks, ReadinessChecks and StartupChecks are the names of the
+	// checks performed by the respective probe.
+	LivenessChecks  []string
+	ReadinessChecks []string
+	StartupChecks   []string
+	// PingCount, PingInterval, PingTimeout, PingSize, PingMode, PingTCPPort,
+	// PingMaxLoss, PingMaxAvgRTT and PingMaxP99RTT configure the ping check,
+	// see ping.Config.
+	PingCount     int
+	PingInterval  time.Duration
+	PingTimeout   time.Duration
+	PingSize      int
+	PingMode      ping.Mode
+	PingTCPPort   int
+	PingMaxLoss   float64
+	PingMaxAvgRTT time.Duration
+	PingMaxP99RTT time.Duration
+	// FailureThreshold and SuccessThreshold are the numbers of consecutive
+	// failures and successes after which a check changes its health, see
+	// kvm.Config.
+	FailureThreshold int
+	SuccessThreshold int
+	// ProbeBudget is the time all checks of a health check have to finish in,
+	// see kvm.Config.
+	ProbeBudget time.Duration
+	// Severities maps the names of checks to their severity, overriding the
+	// ones of the Definitions. Checks default to check.SeverityCritical.
+	Severities         map[string]check.Severity
+	StartupGracePeriod time.Duration
+	// StateFile is the path of the file the health state is persisted to,
+	// see persist.Config. Empty disables the persistence.
+	StateFile         string
+	StateMaxAge       time.Duration
+	StateSaveInterval time.Duration
+	// StatusCodes map the overall health to the HTTP status codes of the
+	// health endpoints.
+	StatusCodes StatusCodes
+	Target      kvm.Target
+}
+
+// StatusCodes holds the status codes of every health endpoint. Empty ones
+// default to check.DefaultStatusCodes.
+type StatusCodes struct {
+	Healthz  check.StatusCodes
+	Livez    check.StatusCodes
+	Readyz   check.StatusCodes
+	Startupz check.StatusCodes
 }
 
 // New creates a new configured healthz service.
 func New(config Config) (*Service, error) {
 	var err error
 
-	var kvmService healthz.Service
+	// all known checkers are registered, the config selects which of them are
+	// performed
+	registry := check.NewRegistry()
+	{
+		pingChecker, err := ping.New(ping.Config{
+			Logger: config.Logger,
+
+			Count:     config.PingCount,
+			Interval:  config.PingInterval,
+			Timeout:   config.PingTimeout,
+			Size:      config.PingSize,
+			Mode:      config.PingMode,
+			TCPPort:   config.PingTCPPort,
+			MaxLoss:   config.PingMaxLoss,
+			MaxAvgRTT: config.PingMaxAvgRTT,
+			MaxP99RTT: config.PingMaxP99RTT,
+		})
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		kubeletChecker, err := httpget.New(httpget.Config{
+			Logger: config.Logger,
+
+			Description:         kubeletDescription,
+			ExpectedBody:        config.Kubelet.ExpectedBody,
+			ExpectedStatusCodes: config.Kubelet.ExpectedStatusCodes,
+			Host:                config.Kubelet.Host,
+			LatencyWarning:      config.Kubelet.LatencyWarning,
+			Name:                CheckKubelet,
+			Path:                orDefault(config.Kubelet.Path, defaultPath),
+			Port:                orDefaultInt(config.Kubelet.Port, kubeletPort),
+			Scheme:              orDefault(config.Kubelet.Scheme, kubeletScheme),
+			TLS:                 config.Kubelet.TLS,
+			TokenFile:           config.Kubelet.TokenFile,
+			Verbose:             config.Kubelet.Verbose,
+		})
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		apiChecker, err := httpget.New(httpget.Config{
+			Logger: config.Logger,
+
+			Description:         apiDescription,
+			ExpectedBody:        config.API.ExpectedBody,
+			ExpectedStatusCodes: config.API.ExpectedStatusCodes,
+			Host:                config.API.Host,
+			LatencyWarning:      config.API.LatencyWarning,
+			Name:                CheckAPI,
+			Path:                orDefault(config.API.Path, defaultPath),
+			Port:                orDefaultInt(config.API.Port, apiPort),
+			Scheme:              orDefault(config.API.Scheme, apiScheme),
+			TLS:                 config.API.TLS,
+			TokenFile:           config.API.TokenFile,
+			Verbose:             config.API.Verbose,
+		})
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		for _, c := range []check.Checker{pingChecker, kubeletChecker, apiChecker} {
+			err = registry.Register(c)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+		}
+
+		for _, d := range config.Definitions {
+			c, err := definition.New(definition.Config{
+				Logger:     config.Logger,
+				Definition: d,
+			})
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+
+			err = registry.Register(c)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+
+			if d.Severity != "" {
+				err = registry.SetSeverity(d.Name, check.Severity(d.Severity))
+				if err != nil {
+					return nil, microerror.Mask(err)
+				}
+			}
+		}
+
+		for name, severity := range config.Severities {
+			err = registry.SetSeverity(name, severity)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+		}
+
+		for name, dependencies := range config.Dependencies {
+			err = registry.AddDependencies(name, dependencies)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+		}
+
+		err = registry.Validate()
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	var kvmService *kvm.Service
 	{
 		kvmServiceConfig := kvm.Config{
-			CheckAPI: config.CheckAPI,
-			IP:       config.IPAddress,
 			Logger:   config.Logger,
+			Registry: registry,
+
+			CheckInterval:      config.CheckInterval,
+			CheckJitter:        config.CheckJitter,
+			FailureThreshold:   config.FailureThreshold,
+			HistorySize:        config.HistorySize,
+			ProbeBudget:        config.ProbeBudget,
+			SuccessThreshold:   config.SuccessThreshold,
+			Checks:             config.Checks,
+			StartupGracePeriod: config.StartupGracePeriod,
+			Target:             config.Target,
 		}
 
 		kvmService, err = kvm.New(kvmServiceConfig)
@@ -34,8 +274,88 @@ func New(config Config) (*Service, error) {
 		}
 	}
 
+	// liveness only fails because of the kvm in case checks are configured
+	// explicitly, and then honours the startup grace period
+	var livenessService *probe.Service
+	{
+		livenessConfig := probe.Config{
+			KVM:    kvmService,
+			Logger: config.Logger,
+
+			Checks:      config.LivenessChecks,
+			Description: livenessDescription,
+			Name:        livenessName,
+		}
+
+		livenessService, err = probe.New(livenessConfig)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	var readinessService *probe.Service
+	{
+		readinessConfig := probe.Config{
+			KVM:    kvmService,
+			Logger: config.Logger,
+
+			Checks:           config.ReadinessChecks,
+			Description:      readinessDescription,
+			FailInitializing: true,
+			Name:             readinessName,
+		}
+
+		readinessService, err = probe.New(readinessConfig)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	// startup succeeds forever once the kvm has been healthy
+	var startupService *probe.Service
+	{
+		startupConfig := probe.Config{
+			KVM:    kvmService,
+			Logger: config.Logger,
+
+			Checks:           config.StartupChecks,
+			Description:      startupDescription,
+			FailInitializing: true,
+			Name:             startupName,
+			Sticky:           true,
+		}
+
+		startupService, err = probe.New(startupConfig)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	var persistService *persist.Service
+	if config.StateFile != "" {
+		persistConfig := persist.Config{
+			KVM:    kvmService,
+			Logger: config.Logger,
+			Probes: []*probe.Service{livenessService, readinessService, startupService},
+
+			File:     config.StateFile,
+			Interval: config.StateSaveInterval,
+			MaxAge:   config.StateMaxAge,
+		}
+
+		persistService, err = persist.New(persistConfig)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
 	newService := &Service{
-		KVM: kvmService,
+		KVM:         kvmService,
+		Persist:     persistService,
+		Liveness:    livenessService,
+		Readiness:   readinessService,
+		Startup:     startupService,
+		StatusCodes: config.StatusCodes,
 	}
 
 	return newService, nil
@@ -43,5 +363,30 @@ func New(config Config) (*Service, error) {
 
 // Service is the healthz service collection.
 type Service struct {
-	KVM healthz.Service
+	KVM *kvm.Service
+	// Persist saves and restores the health state. It is nil in case no state
+	// file is configured.
+	Persist   *persist.Service
+	Liveness  *probe.Service
+	Readiness *probe.Service
+	Startup   *probe.Service
+	// StatusCodes are the status codes the health endpoints serve the
+	// responses of the services with.
+	StatusCodes StatusCodes
+}
+
+func orDefault(value string, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+
+	return value
+}
+
+func orDefaultInt(value int, defaultValue int) int {
+	if value == 0 {
+		return defaultValue
+	}
+
+	return value
 }
@@ -0,0 +1,93 @@
+package kvm
+
+import (
+	"sync"
+
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check"
+)
+
+// performFunc performs a single checker against a single IP of the KVM.
+type performFunc func(c check.Checker) check.Result
+
+// runGraph performs the given checkers, together with the checkers they depend
+// on, against a single IP of the KVM. Every checker is performed concurrently
+// as soon as all of its dependencies succeeded, so that independent checkers
+// do not wait for each other. A checker is skipped in case any of its
+// dependencies did not succeed. The results of the given checkers are returned
+// in the given order.
+func (s *Service) runGraph(target check.Target, checkers []check.Checker, perform performFunc) []check.Result {
+	nodes := s.graphNodes(checkers)
+
+	var wg sync.WaitGroup
+	for _, n := range nodes {
+		wg.Add(1)
+		go func(n *graphNode) {
+			defer wg.Done()
+			defer close(n.done)
+
+			for _, d := range n.dependencies {
+				dependency := nodes[d]
+				<-dependency.done
+
+				if dependency.result.Failed() {
+					n.result = check.Skip(n.checker, target, dependency.result)
+					return
+				}
+			}
+
+			n.result = perform(n.checker)
+		}(n)
+	}
+	wg.Wait()
+
+	var results []check.Result
+	for _, c := range checkers {
+		results = append(results, nodes[c.Name()].result)
+	}
+
+	return results
+}
+
+// graphNode is a checker in the dependency graph. Its result may only be read
+// once done is closed.
+type graphNode struct {
+	checker      check.Checker
+	dependencies []string
+	done         chan struct{}
+	result       check.Result
+}
+
+// graphNodes returns the nodes of the given checkers and of all checkers they
+// depend on, directly or indirectly, by name. The registry guarantees that the
+// dependencies exist and do not form cycles.
+func (s *Service) graphNodes(checkers []check.Checker) map[string]*graphNode {
+	nodes := map[string]*graphNode{}
+
+	queue := append([]check.Checker{}, checkers...)
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+
+		if _, ok := nodes[c.Name()]; ok {
+			continue
+		}
+
+		n := &graphNode{
+			checker:      c,
+			dependencies: s.registry.Dependencies(c.Name()),
+			done:         make(chan struct{}),
+		}
+		nodes[c.Name()] = n
+
+		dependencies, err := s.registry.Enabled(n.dependencies)
+		if err != nil {
+			// not possible for a validated registry, the dependencies are
+			// dropped so that the checker is still performed
+			n.dependencies = nil
+			continue
+		}
+		queue = append(queue, dependencies...)
+	}
+
+	return nodes
+}
@@ -0,0 +1,135 @@
+package kvm
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check"
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check/checktest"
+)
+
+func Test_KVM_RunGraph(t *testing.T) {
+	tests := []struct {
+		pingStatus       check.Status
+		dependencies     map[string][]string
+		expectedStatuses []check.Status
+	}{
+		// test 0 - independent checks
+		{
+			pingStatus:       check.StatusFailed,
+			dependencies:     nil,
+			expectedStatuses: []check.Status{check.StatusFailed, check.StatusOK, check.StatusOK},
+		},
+		// test 1 - dependencies succeeded
+		{
+			pingStatus: check.StatusOK,
+			dependencies: map[string][]string{
+				"kubelet": {"ping"},
+				"api":     {"kubelet"},
+			},
+			expectedStatuses: []check.Status{check.StatusOK, check.StatusOK, check.StatusOK},
+		},
+		// test 2 - dependency failed, skipped transitively
+		{
+			pingStatus: check.StatusFailed,
+			dependencies: map[string][]string{
+				"kubelet": {"ping"},
+				"api":     {"kubelet"},
+			},
+			expectedStatuses: []check.Status{check.StatusFailed, check.StatusSkipped, check.StatusSkipped},
+		},
+	}
+
+	for index, test := range tests {
+		s := newTestService(t, Config{},
+			checktest.New(checktest.Config{Name: "ping", Sleep: 100 * time.Millisecond, Status: test.pingStatus}),
+			checktest.New(checktest.Config{Name: "kubelet", Sleep: 100 * time.Millisecond, Dependencies: test.dependencies["kubelet"]}),
+			checktest.New(checktest.Config{Name: "api", Sleep: 100 * time.Millisecond, Dependencies: test.dependencies["api"]}),
+		)
+
+		start := time.Now()
+		response, err := s.GetHealthzResponse(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		duration := time.Since(start)
+
+		if len(response.Checks) != len(test.expectedStatuses) {
+			t.Fatalf("%d: expected %d results got %d", index, len(test.expectedStatuses), len(response.Checks))
+		}
+		for i, r := range response.Checks {
+			if r.Status != test.expectedStatuses[i] {
+				t.Fatalf("%d: expected status %s of %s got %s", index, test.expectedStatuses[i], r.Name, r.Status)
+			}
+		}
+		if test.dependencies == nil && duration > 250*time.Millisecond {
+			t.Fatalf("%d: expected independent checks to run concurrently, took %s", index, duration)
+		}
+	}
+}
+
+func Test_KVM_ProbeBudget(t *testing.T) {
+	s := newTestService(t, Config{ProbeBudget: 100 * time.Millisecond},
+		checktest.New(checktest.Config{Name: "blocking", Block: true}),
+		checktest.New(checktest.Config{Name: "ping"}),
+	)
+
+	start := time.Now()
+	response, err := s.GetHealthzResponse(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatalf("expected checks to be canceled after the probe budget, took %s", time.Since(start))
+	}
+
+	if !response.Failed {
+		t.Fatalf("expected response to fail")
+	}
+	if response.Checks[0].Status != check.StatusTimeout {
+		t.Fatalf("expected status %s got %s", check.StatusTimeout, response.Checks[0].Status)
+	}
+	if response.Checks[1].Status != check.StatusOK {
+		t.Fatalf("expected status %s got %s", check.StatusOK, response.Checks[1].Status)
+	}
+}
+
+func Test_KVM_RunGraph_SharedDependency(t *testing.T) {
+	ping := checktest.New(checktest.Config{Name: "ping", Status: check.StatusFailed})
+	s := newTestService(t, Config{FailureThreshold: 3},
+		ping,
+		checktest.New(checktest.Config{Name: "kubelet", Dependencies: []string{"ping"}}),
+		checktest.New(checktest.Config{Name: "api", Dependencies: []string{"ping"}}),
+	)
+
+	for index := 0; index < 2; index++ {
+		// the checker services of a single request share the run, like the ones
+		// of /healthz do
+		ctx := WithRun(context.Background())
+
+		checkers := s.Checkers()
+		responses := make([]check.Response, len(checkers))
+		var wg sync.WaitGroup
+		for i, c := range checkers {
+			wg.Add(1)
+			go func(i int, c *CheckerService) {
+				defer wg.Done()
+				var err error
+				responses[i], err = c.GetHealthzResponse(ctx)
+				if err != nil {
+					t.Error(err)
+				}
+			}(i, c)
+		}
+		wg.Wait()
+
+		if ping.Count() != index+1 {
+			t.Fatalf("%d: expected ping to be performed %d times got %d", index, index+1, ping.Count())
+		}
+		if responses[0].Checks[0].ConsecutiveFailures != index+1 {
+			t.Fatalf("%d: expected %d consecutive failures got %d", index, index+1, responses[0].Checks[0].ConsecutiveFailures)
+		}
+	}
+}
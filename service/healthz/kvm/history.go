@@ -0,0 +1,227 @@
+package kvm
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check"
+)
+
+// Event is a single result of a checker against a single IP recorded in the
+// history, together with the state of the check before and after it. Changes
+// of the overall health of the KVM are recorded as transitions of the check
+// Name without a target.
+type Event struct {
+	Timestamp time.Time
+	Check     string
+	Target    string
+	Status    check.Status
+	Latency   time.Duration
+	// Message is the error of failed results and the message of successful
+	// ones.
+	Message  string
+	OldState check.State
+	NewState check.State
+}
+
+// Transition returns true in case the result changed the state of the check.
+func (e Event) Transition() bool {
+	return e.OldState != e.NewState
+}
+
+// MarshalJSON encodes the latency in milliseconds like check.Result does and
+// tells whether the event is a transition.
+func (e Event) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Timestamp  time.Time    `json:"timestamp"`
+		Check      string       `json:"check"`
+		Target     string       `json:"target,omitempty"`
+		Status     check.Status `json:"status,omitempty"`
+		LatencyMS  float64      `json:"latency_ms"`
+		Message    string       `json:"message,omitempty"`
+		OldState   check.State  `json:"old_state,omitempty"`
+		NewState   check.State  `json:"new_state,omitempty"`
+		Transition bool         `json:"transition"`
+	}{
+		Timestamp:  e.Timestamp,
+		Check:      e.Check,
+		Target:     e.Target,
+		Status:     e.Status,
+		LatencyMS:  check.Milliseconds(e.Latency),
+		Message:    e.Message,
+		OldState:   e.OldState,
+		NewState:   e.NewState,
+		Transition: e.Transition(),
+	})
+}
+
+// HistoryFilter selects the events returned by History. Empty fields do not
+// filter.
+type HistoryFilter struct {
+	// Checks are the names of the checks whose events are returned.
+	Checks []string
+	// Since and Until limit the events to the ones of results started within
+	// the given time range, both inclusive.
+	Since time.Time
+	Until time.Time
+	// Transitions only returns the events which changed the state of their
+	// check.
+	Transitions bool
+	// Limit is the maximum number of events returned. The most recent ones
+	// are kept.
+	Limit int
+}
+
+func (f HistoryFilter) matches(e Event) bool {
+	if len(f.Checks) != 0 {
+		var found bool
+		for _, c := range f.Checks {
+			if c == e.Check {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if !f.Since.IsZero() && e.Timestamp.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && e.Timestamp.After(f.Until) {
+		return false
+	}
+	if f.Transitions && !e.Transition() {
+		return false
+	}
+
+	return true
+}
+
+// history is a ring buffer of the most recent events. Once it is full the
+// oldest event is overwritten.
+type history struct {
+	mutex  sync.Mutex
+	events []Event
+	next   int
+	full   bool
+}
+
+func newHistory(size int) *history {
+	return &history{
+		events: make([]Event, size),
+	}
+}
+
+func (h *history) add(e Event) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if len(h.events) == 0 {
+		return
+	}
+
+	h.events[h.next] = e
+	h.next = (h.next + 1) % len(h.events)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// list returns the events matching the given filter, the oldest first.
+func (h *history) list(filter HistoryFilter) []Event {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	var ordered []Event
+	if h.full {
+		ordered = append(ordered, h.events[h.next:]...)
+	}
+	ordered = append(ordered, h.events[:h.next]...)
+
+	events := []Event{}
+	for _, e := range ordered {
+		if filter.matches(e) {
+			events = append(events, e)
+		}
+	}
+
+	return events
+}
+
+// overall is the overall health of the KVM derived from the states of its
+// checks, as last recorded in the history.
+type overall struct {
+	mutex sync.Mutex
+	state check.State
+}
+
+// History returns the recorded results of the checkers and the transitions of
+// their states matching the given filter, the oldest first. The history is
+// kept in memory and only holds the most recent events, see
+// Config.HistorySize. Transitions are kept apart from the other results, so
+// that frequent results do not push them out of the history.
+func (s *Service) History(filter HistoryFilter) []Event {
+	events := s.transitions.list(filter)
+	if !filter.Transitions {
+		events = append(events, s.history.list(filter)...)
+		sort.SliceStable(events, func(i, j int) bool {
+			return events[i].Timestamp.Before(events[j].Timestamp)
+		})
+	}
+
+	if filter.Limit > 0 && len(events) > filter.Limit {
+		events = events[len(events)-filter.Limit:]
+	}
+
+	return events
+}
+
+// record updates the state of the check of the given result and adds the
+// result to the history. Results changing the state of their check, or the
+// overall health of the KVM, are added to the transitions.
+func (s *Service) record(r check.Result) {
+	oldState, newState := s.states.record(r)
+
+	message := r.Message
+	if r.Failed() {
+		message = r.Error
+	}
+
+	e := Event{
+		Timestamp: r.Timestamp,
+		Check:     r.Name,
+		Target:    r.Target,
+		Status:    r.Status,
+		Latency:   r.Latency,
+		Message:   message,
+		OldState:  oldState,
+		NewState:  newState,
+	}
+	if !e.Transition() {
+		s.history.add(e)
+		return
+	}
+	s.transitions.add(e)
+	s.notifyChanged()
+
+	s.overall.mutex.Lock()
+	defer s.overall.mutex.Unlock()
+
+	state := s.states.worst(s.Checks(), s.Target().IPs, s.registry.Severity)
+	if state == s.overall.state {
+		return
+	}
+
+	s.transitions.add(Event{
+		Timestamp: r.Timestamp,
+		Check:     Name,
+		Message:   fmt.Sprintf("Changed by check %s of %s. %s", r.Name, r.Target, message),
+		OldState:  s.overall.state,
+		NewState:  state,
+	})
+	s.overall.state = state
+}
@@ -0,0 +1,128 @@
+package kvm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check"
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check/checktest"
+)
+
+func Test_KVM_History(t *testing.T) {
+	start := time.Date(2020, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	// three results of ping, failing in the middle, and two of kubelet
+	s := newTestService(t, Config{HistorySize: 10},
+		checktest.New(checktest.Config{Name: "ping"}),
+		checktest.New(checktest.Config{Name: "kubelet"}),
+	)
+	for i, r := range []check.Result{
+		{Name: "ping", Status: check.StatusOK},
+		{Name: "kubelet", Status: check.StatusOK},
+		{Name: "ping", Status: check.StatusFailed, Error: "lost"},
+		{Name: "kubelet", Status: check.StatusOK},
+		{Name: "ping", Status: check.StatusOK},
+	} {
+		r.Target = "172.23.3.66"
+		r.Timestamp = start.Add(time.Duration(i) * time.Minute)
+		s.record(r)
+	}
+
+	tests := []struct {
+		filter             HistoryFilter
+		expectedTimestamps []int
+	}{
+		// test 0 - all results and the transitions of the overall health
+		{
+			filter:             HistoryFilter{},
+			expectedTimestamps: []int{0, 0, 1, 2, 2, 3, 4, 4},
+		},
+		// test 1 - filtered by check
+		{
+			filter:             HistoryFilter{Checks: []string{"ping"}},
+			expectedTimestamps: []int{0, 2, 4},
+		},
+		// test 2 - the overall health
+		{
+			filter:             HistoryFilter{Checks: []string{Name}},
+			expectedTimestamps: []int{0, 2, 4},
+		},
+		// test 3 - filtered by time
+		{
+			filter:             HistoryFilter{Since: start.Add(2 * time.Minute), Until: start.Add(3 * time.Minute)},
+			expectedTimestamps: []int{2, 2, 3},
+		},
+		// test 4 - transitions only
+		{
+			filter:             HistoryFilter{Transitions: true},
+			expectedTimestamps: []int{0, 0, 1, 2, 2, 4, 4},
+		},
+		// test 5 - the most recent events are kept
+		{
+			filter:             HistoryFilter{Limit: 1},
+			expectedTimestamps: []int{4},
+		},
+	}
+
+	for index, test := range tests {
+		events := s.History(test.filter)
+
+		var timestamps []int
+		for _, e := range events {
+			timestamps = append(timestamps, int(e.Timestamp.Sub(start)/time.Minute))
+		}
+		if len(timestamps) != len(test.expectedTimestamps) {
+			t.Fatalf("%d: expected events %v got %v", index, test.expectedTimestamps, timestamps)
+		}
+		for i := range timestamps {
+			if timestamps[i] != test.expectedTimestamps[i] {
+				t.Fatalf("%d: expected events %v got %v", index, test.expectedTimestamps, timestamps)
+			}
+		}
+	}
+
+	failed := s.History(HistoryFilter{Checks: []string{"ping"}, Transitions: true})[1]
+	if failed.OldState != check.StateHealthy || failed.NewState != check.StateUnhealthy || failed.Message != "lost" {
+		t.Fatalf("expected transition from %s to %s with message lost got %#v", check.StateHealthy, check.StateUnhealthy, failed)
+	}
+
+	overall := s.History(HistoryFilter{Checks: []string{Name}})
+	for i, expected := range []check.State{check.StateHealthy, check.StateUnhealthy, check.StateHealthy} {
+		if overall[i].NewState != expected {
+			t.Fatalf("expected overall health %s got %#v", expected, overall[i])
+		}
+	}
+}
+
+func Test_KVM_History_Transitions(t *testing.T) {
+	start := time.Date(2020, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	// a single transition followed by many more results than the history
+	// holds
+	s := newTestService(t, Config{HistorySize: 4},
+		checktest.New(checktest.Config{Name: "ping"}),
+	)
+	for i := 0; i < 20; i++ {
+		s.record(check.Result{
+			Name:      "ping",
+			Target:    "172.23.3.66",
+			Status:    check.StatusOK,
+			Timestamp: start.Add(time.Duration(i) * time.Minute),
+		})
+	}
+
+	transitions := s.History(HistoryFilter{Transitions: true})
+	if len(transitions) != 2 {
+		t.Fatalf("expected the transitions of ping and the overall health got %#v", transitions)
+	}
+	for _, e := range transitions {
+		if !e.Timestamp.Equal(start) || e.NewState != check.StateHealthy {
+			t.Fatalf("expected transition to %s at %s got %#v", check.StateHealthy, start, e)
+		}
+	}
+
+	events := s.History(HistoryFilter{})
+	if len(events) != 6 || !events[0].Timestamp.Equal(start) || !events[5].Timestamp.Equal(start.Add(19*time.Minute)) {
+		t.Fatalf("expected the transitions and the 4 most recent results got %#v", events)
+	}
+}
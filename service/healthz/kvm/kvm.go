@@ -2,16 +2,19 @@ package kvm
 
 import (
 	"context"
-	"crypto/tls"
 	"fmt"
-	"net/http"
-	"net/url"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/giantswarm/microendpoint/service/healthz"
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger"
-	"github.com/sparrc/go-ping"
+
+	"github.com/giantswarm/k8s-kvm-health/service/address"
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check"
 )
 
 const (
@@ -20,156 +23,399 @@ const (
 	// Name is the identifier of the health check. This can be used for emitting
 	// metrics.
 	Name = "kvmHealthz"
+	// InitializingMessage prefixes the message returned while the KVM to probe
+	// is not yet known.
+	InitializingMessage = "Initializing."
 
-	// config
-	pingCount         = 1
-	httpsScheme       = "https"
-	httpScheme        = "http"
-	k8sAPIPort        = 443
-	k8sKubeletPort    = 10248
-	maxIdleConnection = 10
-	maxTimeoutSec     = 4
+	// DefaultHistorySize is the number of results kept in the history by
+	// default, e.g. about an hour of three checks performed every 10s.
+	DefaultHistorySize = 1000
 )
 
 // Config represents the configuration used to create a healthz service.
 type Config struct {
 	// Dependencies.
-	CheckAPI bool
-	IP       string
 	Logger   micrologger.Logger
+	Registry *check.Registry
+
+	// Settings.
+	// CheckInterval is the interval the checkers are performed at in the
+	// background. Health checks serve the cached results. Zero disables the
+	// background checks, so that every health check performs its checkers
+	// synchronously.
+	CheckInterval time.Duration
+	// CheckJitter is the maximum random delay added to every check interval.
+	CheckJitter time.Duration
+	// Checks are the names of the checkers of the registry which are enabled,
+	// in the order they are performed.
+	Checks []string
+	// HistorySize is the number of results of the checkers kept in memory,
+	// see History. The same number of state transitions is kept apart from
+	// them. Defaults to DefaultHistorySize.
+	HistorySize int
+	// FailureThreshold is the number of consecutive failures after which a
+	// check is unhealthy, and SuccessThreshold the number of consecutive
+	// successes after which it is healthy again. Checks in between are
+	// degraded, which does not fail the health checks. Both default to 1.
+	FailureThreshold int
+	SuccessThreshold int
+	// ProbeBudget is the time all checks of a health check, or of a run of the
+	// background checks, have to finish in. Checks still running when it is
+	// exceeded are canceled and reported as timed out. Zero disables the
+	// budget, so that only the request context limits the checks.
+	ProbeBudget time.Duration
+	// StartupGracePeriod is the time after the creation of the service during
+	// which the health check does not fail while it is still initializing.
+	StartupGracePeriod time.Duration
+	// Target is the KVM initially probed. It is optional. Without a target the
+	// service is initializing until a target is set using SetTarget.
+	Target Target
 }
 
 // Service implements the healthz service interface.
 type Service struct {
 	// Dependencies.
-	checkAPI bool
-	client   *http.Client
-	ip       string
 	logger   micrologger.Logger
-	tr       *http.Transport
+	registry *check.Registry
+
+	// Internals.
+	cache       *cache
+	changed     chan struct{}
+	checkers    []check.Checker
+	created     time.Time
+	history     *history
+	overall     overall
+	pending     pending
+	states      *states
+	target      atomic.Value
+	targetMutex sync.Mutex
+	transitions *history
+
+	// Settings.
+	checkInterval      time.Duration
+	checkJitter        time.Duration
+	probeBudget        time.Duration
+	startupGracePeriod time.Duration
 }
 
 // New creates a new configured healthz service.
 func New(config Config) (*Service, error) {
 	// Dependencies.
-	if config.IP == "" {
-		return nil, microerror.Maskf(invalidConfigError, "config.IP must not be empty string")
-	}
 	if config.Logger == nil {
 		return nil, microerror.Maskf(invalidConfigError, "config.Logger must not be empty")
 	}
-
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, // nolint
-		MaxIdleConns:    maxIdleConnection,
+	if config.Registry == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.Registry must not be empty")
 	}
 
-	client := &http.Client{
-		Transport: tr,
-		Timeout:   maxTimeoutSec * time.Second,
+	// Settings.
+	checkers, err := config.Registry.Enabled(config.Checks)
+	if err != nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.Checks: %s", err)
+	}
+	if config.CheckInterval < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.CheckInterval must not be negative")
+	}
+	if config.CheckJitter < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.CheckJitter must not be negative")
+	}
+	if config.HistorySize == 0 {
+		config.HistorySize = DefaultHistorySize
+	}
+	if config.HistorySize < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.HistorySize must be positive, got %d", config.HistorySize)
+	}
+	if config.FailureThreshold == 0 {
+		config.FailureThreshold = 1
+	}
+	if config.FailureThreshold < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.FailureThreshold must be positive, got %d", config.FailureThreshold)
+	}
+	if config.SuccessThreshold == 0 {
+		config.SuccessThreshold = 1
+	}
+	if config.SuccessThreshold < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.SuccessThreshold must be positive, got %d", config.SuccessThreshold)
+	}
+	if config.ProbeBudget < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.ProbeBudget must not be negative")
+	}
+	if config.StartupGracePeriod < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.StartupGracePeriod must not be negative")
+	}
+	if len(config.Target.IPs) != 0 {
+		err := config.Target.validate()
+		if err != nil {
+			return nil, microerror.Maskf(invalidConfigError, "config.Target: %s", err)
+		}
+		config.Target.LastChanged = time.Now()
 	}
 
 	newService := &Service{
 		// Dependencies.
-		checkAPI: config.CheckAPI,
-		client:   client,
-		ip:       config.IP,
 		logger:   config.Logger,
-		tr:       tr,
+		registry: config.Registry,
+
+		// Internals.
+		cache:    newCache(checkers),
+		changed:  make(chan struct{}, 1),
+		checkers: checkers,
+		created:  time.Now(),
+		history:  newHistory(config.HistorySize),
+		pending: pending{
+			reason: "waiting for target",
+		},
+		states:      newStates(config.FailureThreshold, config.SuccessThreshold),
+		transitions: newHistory(config.HistorySize),
+
+		// Settings.
+		checkInterval:      config.CheckInterval,
+		checkJitter:        config.CheckJitter,
+		probeBudget:        config.ProbeBudget,
+		startupGracePeriod: config.StartupGracePeriod,
+	}
+
+	newService.target.Store(config.Target)
+	if len(config.Target.IPs) != 0 {
+		setTargetInfo(config.Target)
 	}
 
 	return newService, nil
 }
 
 // GetHealthz Provides Healthz implementation to check health status of network
-// interface. It performs following checks in given order:
-//  - Ping configured IP.
-//  - Check that Kubelet instance in configured IP responds to HTTP request.
-//  - Check that K8s API in configured IP responds to HTTPS request.
+// interface. It performs the enabled checks for each configured IP, by
+// default:
+//   - Ping configured IP.
+//   - Check that Kubelet instance in configured IP responds to HTTP request.
+//   - Check that K8s API in configured IP responds to HTTPS request.
+//
+// Every check is performed on its own and concurrently, so that a failing
+// ping does not hide the state of the kubelet, unless the kubelet check is
+// configured to depend on the ping check. Checks whose dependencies failed
+// are reported as skipped. The health check fails in case any critical check
+// of any of the address families is unhealthy. Unhealthy checks of severity
+// warning only degrade the health, the ones of severity info are only
+// reported. The message contains the result of
+// every family. See GetHealthzResponse for the results of the single checks.
+//
+// In case a check interval is configured, the checks are performed in the
+// background by Run and their cached results are served. See check.WithFresh
+// for how to ask for fresh results instead.
+//
+// As long as no target is known, the service is initializing and no checks
+// are performed. See Initializing for when this counts as failed.
 func (s *Service) GetHealthz(ctx context.Context) (healthz.Response, error) {
-	var apiFailed, kubeletFailed, pingFailed bool
-	var apiMsg, kubeletMsg, pingMsg string
-	pingFailed, pingMsg = s.pingHealthCheck()
+	response, err := s.GetHealthzResponse(ctx)
+	if err != nil {
+		return healthz.Response{}, microerror.Mask(err)
+	}
+
+	return response.Response, nil
+}
 
-	response := healthz.Response{
-		Description: Description,
-		Failed:      pingFailed,
-		Message:     pingMsg,
-		Name:        Name,
+// GetHealthzResponse works like GetHealthz but also returns the results of the
+// single checks.
+func (s *Service) GetHealthzResponse(ctx context.Context) (check.Response, error) {
+	return s.runCheckers(ctx, Name, Description, s.checkers), nil
+}
+
+// GetHealthzChecks works like GetHealthzResponse but performs the checkers of
+// the given names, in the given order, instead of the enabled ones.
+func (s *Service) GetHealthzChecks(ctx context.Context, checks []string) (check.Response, error) {
+	checkers, err := s.registry.Enabled(checks)
+	if err != nil {
+		return check.Response{}, microerror.Mask(err)
 	}
 
-	// check kubelet only if ping succeeded
-	if !pingFailed {
-		kubeletFailed, kubeletMsg = s.httpHealthCheck(k8sKubeletPort, httpScheme)
-		response.Failed = kubeletFailed
-		response.Message = kubeletMsg
+	return s.runCheckers(ctx, Name, Description, checkers), nil
+}
+
+// Checkers returns a healthz service for each of the enabled checkers, in the
+// order they are performed.
+func (s *Service) Checkers() []*CheckerService {
+	var services []*CheckerService
+	for _, c := range s.checkers {
+		services = append(services, &CheckerService{checker: c, kvm: s})
 	}
 
-	// check api only if ping and kubelet succeeded
-	if !pingFailed && !kubeletFailed && s.checkAPI {
-		apiFailed, apiMsg = s.httpHealthCheck(k8sAPIPort, httpsScheme)
-		response.Failed = apiFailed
-		response.Message = apiMsg
+	return services
+}
+
+// Checks returns the names of the enabled checkers in the order they are
+// performed.
+func (s *Service) Checks() []string {
+	var names []string
+	for _, c := range s.checkers {
+		names = append(names, c.Name())
 	}
 
-	return response, nil
+	return names
 }
 
-func (s *Service) pingHealthCheck() (bool, string) {
-	var message string
-	// ping kvm
-	pinger, err := ping.NewPinger(s.ip)
-	if err != nil {
-		message = "Failed to init pinger."
-		return true, message
+// Registry returns the registry the checkers of the service are taken from.
+func (s *Service) Registry() *check.Registry {
+	return s.registry
+}
+
+// runCheckers performs the given checkers against every IP of the current
+// target and aggregates their results.
+func (s *Service) runCheckers(ctx context.Context, name string, description string, checkers []check.Checker) check.Response {
+	response := check.Response{
+		Response: healthz.Response{
+			Description: description,
+			Name:        name,
+		},
 	}
-	// set fail values
-	var failed = true
-	message = fmt.Sprintf("Healthcheck for KVM has failed. KVM is not responding on  %s.", s.ip)
 
-	pinger.Count = pingCount
-	pinger.Timeout = time.Second * 1
-	pinger.SetPrivileged(true)
-	pinger.OnRecv = func(pkt *ping.Packet) {
-		// we got positive response
-		failed = false
-		message = fmt.Sprintf("Healthcheck for KVM has been successful. KVM is live and responding. on %s.", s.ip)
+	initializing, failed, reason := s.Initializing()
+	if initializing {
+		response.Failed = failed
+		response.Message = fmt.Sprintf("%s %s", InitializingMessage, reason)
+		response.State = check.StateInitializing
+		return response
 	}
 
-	pinger.Run()
+	target := s.Target()
+
+	ctx, cancel := s.withProbeBudget(ctx)
+	defer cancel()
+
+	// the IPs are checked concurrently, their results are reported in the
+	// order of the target
+	resultsByIP := make([][]check.Result, len(target.IPs))
+	var wg sync.WaitGroup
+	for i, ip := range target.IPs {
+		wg.Add(1)
+		go func(i int, ip string) {
+			defer wg.Done()
+			resultsByIP[i] = s.checkIP(ctx, target, ip, checkers)
+		}(i, ip)
+	}
+	wg.Wait()
+
+	var messages []string
+	var state check.State
+	for i, ip := range target.IPs {
+		results := resultsByIP[i]
+
+		for i := range results {
+			results[i] = s.states.annotate(results[i])
+			results[i].Severity = s.registry.Severity(results[i].Name)
+		}
+
+		// the message of an IP is the one of its first check making it
+		// unhealthy, or of its first failing check degrading it, or of its
+		// last successful check otherwise, as it used to be when the checks
+		// were chained
+		message := "No checks performed."
+		var degraded string
+	results:
+		for _, r := range results {
+			effect := r.Severity.Effect(stateOf(r))
+			if effect == check.StateHealthy && r.Warning != "" && r.Severity != check.SeverityInfo {
+				// warnings of successful checks, e.g. a slow response, degrade
+				// the health
+				effect = check.StateDegraded
+			}
+			state = check.WorseState(state, effect)
+
+			switch {
+			case effect == check.StateUnhealthy:
+				response.Failed = true
+				message = r.Error
+				degraded = ""
+				break results
+			case effect == check.StateDegraded && r.Failed():
+				if degraded == "" {
+					degraded = degradedMessage(r)
+				}
+				continue
+			case effect == check.StateDegraded && r.Warning != "" && degraded == "":
+				degraded = degradedMessage(r)
+			case r.Failed():
+				// failing checks of severity info are only reported
+				continue
+			}
 
-	// exit
-	return failed, message
+			message = r.Message
+			if r.Warning != "" {
+				message = fmt.Sprintf("%s %s", message, r.Warning)
+			}
+		}
+		if degraded != "" {
+			message = degraded
+		}
+
+		if len(target.IPs) > 1 {
+			message = fmt.Sprintf("[%s] %s", address.FamilyOf(net.ParseIP(ip)), message)
+		}
+		messages = append(messages, message)
+		response.Checks = append(response.Checks, results...)
+	}
+	response.Message = strings.Join(messages, " ")
+	response.State = state
+
+	return response
 }
 
-func (s *Service) httpHealthCheck(port int, scheme string) (bool, string) {
-	var message string
-	u := url.URL{
-		Host:   fmt.Sprintf("%s:%d", s.ip, port),
-		Path:   "healthz",
-		Scheme: scheme,
+// degradedMessage describes why the given result degrades the health, either
+// because of the warning of a successful check or because a failing check
+// does not make it unhealthy.
+func degradedMessage(r check.Result) string {
+	if !r.Failed() {
+		return fmt.Sprintf("Degraded by check %s. %s", r.Name, r.Warning)
+	}
+	if stateOf(r) == check.StateUnhealthy {
+		return fmt.Sprintf("Degraded by %s check %s. %s", r.Severity, r.Name, r.Error)
 	}
 
-	// be sure to close idle connection after health check is finished
-	defer s.tr.CloseIdleConnections()
+	return fmt.Sprintf("Degraded after %d consecutive failures. %s", r.ConsecutiveFailures, r.Error)
+}
 
-	req, err := http.NewRequest("GET", u.String(), nil)
-	if err != nil {
-		panic(fmt.Sprintf("unable to construct health check request: %q", err))
+// withProbeBudget derives the context the checks of a single health check or
+// background run are performed with.
+func (s *Service) withProbeBudget(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.probeBudget == 0 {
+		return context.WithCancel(ctx)
 	}
 
-	// close connection after health check request (the TCP connection gets
-	// closed by deferred s.tr.CloseIdleConnections()).
-	req.Header.Add("Connection", "close")
+	return context.WithTimeout(ctx, s.probeBudget)
+}
+
+// checkIP runs the given checkers against a single IP of the KVM. Checkers
+// are performed concurrently, unless they depend on each other, see runGraph.
+// Checkers already performed under the same run are not performed again, see
+// WithRun.
+func (s *Service) checkIP(ctx context.Context, target Target, ip string, checkers []check.Checker) []check.Result {
+	r := runOf(ctx)
+
+	return s.runGraph(target.checkTarget(ip), checkers, func(c check.Checker) check.Result {
+		return r.do(c.Name(), ip, func() check.Result {
+			return s.cachedResult(ctx, target, ip, c)
+		})
+	})
+}
 
-	// send request to http endpoint
-	_, err = s.client.Do(req)
+// CheckerService implements the healthz service interface for a single
+// checker performed against the KVM.
+type CheckerService struct {
+	checker check.Checker
+	kvm     *Service
+}
+
+// GetHealthz performs the checker against every IP of the current target.
+func (s *CheckerService) GetHealthz(ctx context.Context) (healthz.Response, error) {
+	response, err := s.GetHealthzResponse(ctx)
 	if err != nil {
-		message = fmt.Sprintf("Failed to send http request to endpoint %s. %s", u.String(), err)
-		return true, message
+		return healthz.Response{}, microerror.Mask(err)
 	}
 
-	message = fmt.Sprintf("Healthcheck for http endpoint %s has been successful.", u.String())
-	// exit
-	return false, message
+	return response.Response, nil
+}
+
+// GetHealthzResponse works like GetHealthz but also returns the results of the
+// checker for every IP.
+func (s *CheckerService) GetHealthzResponse(ctx context.Context) (check.Response, error) {
+	return s.kvm.runCheckers(ctx, s.checker.Name(), s.checker.Description(), []check.Checker{s.checker}), nil
 }
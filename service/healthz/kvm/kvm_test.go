@@ -0,0 +1,40 @@
+package kvm
+
+import (
+	"testing"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check"
+)
+
+// newTestService creates a kvm service with a registry of the given checkers.
+// Unless set in the given config, all of the checkers are enabled in the given
+// order and performed against a single IP.
+func newTestService(t *testing.T, config Config, checkers ...check.Checker) *Service {
+	registry := check.NewRegistry()
+	for _, c := range checkers {
+		err := registry.Register(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config.Logger = microloggertest.New()
+	config.Registry = registry
+	if config.Checks == nil {
+		config.Checks = registry.Names()
+	}
+	if config.Target.IPs == nil {
+		config.Target = Target{
+			IPs: []string{"172.23.3.66"},
+		}
+	}
+
+	s, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
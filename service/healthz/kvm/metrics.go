@@ -0,0 +1,41 @@
+package kvm
+
+import (
+	"net"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/giantswarm/k8s-kvm-health/service/address"
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check"
+)
+
+var (
+	targetInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: check.PrometheusNamespace,
+			Subsystem: "target",
+			Name:      "info",
+			Help:      "IPs of the KVM currently probed. The value is always 1.",
+		},
+		[]string{"ip", "family", "network"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(targetInfo)
+}
+
+// setTargetInfo replaces the target info metric by the IPs of the given
+// target.
+func setTargetInfo(target Target) {
+	targetInfo.Reset()
+
+	for _, ip := range target.IPs {
+		var network string
+		if n := target.network(ip); n != nil {
+			network = n.String()
+		}
+
+		targetInfo.WithLabelValues(ip, string(address.FamilyOf(net.ParseIP(ip))), network).Set(1)
+	}
+}
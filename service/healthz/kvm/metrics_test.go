@@ -0,0 +1,37 @@
+package kvm
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func Test_KVM_TargetInfo(t *testing.T) {
+	_, network, err := net.ParseCIDR("172.23.3.64/30")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := newTestService(t, Config{})
+	err = s.SetTarget(Target{IPs: []string{"172.23.3.66"}, Networks: []*net.IPNet{network}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// microkit serves the default registry at /metrics the same way
+	w := httptest.NewRecorder()
+	promhttp.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	series := `k8s_kvm_health_target_info{family="ipv4",ip="172.23.3.66",network="172.23.3.64/30"} 1`
+	if !strings.Contains(string(body), series) {
+		t.Fatalf("expected series %s in\n%s", series, body)
+	}
+}
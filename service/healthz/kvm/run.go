@@ -0,0 +1,70 @@
+package kvm
+
+import (
+	"context"
+	"sync"
+
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check"
+)
+
+type runKey struct{}
+
+// WithRun returns a context under which all health checks of the service
+// share the results of their checkers, so that every checker is performed
+// and recorded at most once against every IP. It is meant for a single
+// request asking several health checks at once, e.g. the one of every checker
+// on /healthz, which would otherwise perform a dependency shared by several
+// checkers once for each of them and count its failures several times.
+func WithRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, runKey{}, &run{
+		results: map[cacheKey]*runResult{},
+	})
+}
+
+// run holds the results of the checkers performed under a context created by
+// WithRun.
+type run struct {
+	mutex   sync.Mutex
+	results map[cacheKey]*runResult
+}
+
+// runResult is the result of a single checker against a single IP. It may
+// only be read once done is closed.
+type runResult struct {
+	done   chan struct{}
+	result check.Result
+}
+
+func runOf(ctx context.Context) *run {
+	r, _ := ctx.Value(runKey{}).(*run)
+	return r
+}
+
+// do returns the result of the given checker against the given IP. Only the
+// first call for them calls perform, the following ones wait for its result.
+// Without a run every call calls perform.
+func (r *run) do(checker string, ip string, perform func() check.Result) check.Result {
+	if r == nil {
+		return perform()
+	}
+
+	key := cacheKey{checker: checker, ip: ip}
+
+	r.mutex.Lock()
+	existing, ok := r.results[key]
+	if !ok {
+		r.results[key] = &runResult{done: make(chan struct{})}
+	}
+	current := r.results[key]
+	r.mutex.Unlock()
+
+	if ok {
+		<-existing.done
+		return existing.result
+	}
+
+	current.result = perform()
+	close(current.done)
+
+	return current.result
+}
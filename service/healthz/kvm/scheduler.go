@@ -0,0 +1,192 @@
+package kvm
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check"
+)
+
+const (
+	// staleIntervals is the number of check intervals after which a cached
+	// result is not served anymore, e.g. because the scheduler got stuck.
+	staleIntervals = 3
+)
+
+// cache holds the last result of every checker for every IP of the target.
+type cache struct {
+	mutex     sync.Mutex
+	results   map[cacheKey]check.Result
+	scheduled map[string]check.Checker
+}
+
+type cacheKey struct {
+	checker string
+	ip      string
+}
+
+func newCache(checkers []check.Checker) *cache {
+	c := &cache{
+		results:   map[cacheKey]check.Result{},
+		scheduled: map[string]check.Checker{},
+	}
+
+	for _, checker := range checkers {
+		c.scheduled[checker.Name()] = checker
+	}
+
+	return c
+}
+
+// get returns the cached result of the given checker and IP in case it is not
+// older than maxAge.
+func (c *cache) get(checker string, ip string, maxAge time.Duration) (check.Result, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	r, ok := c.results[cacheKey{checker: checker, ip: ip}]
+	if !ok {
+		return check.Result{}, false
+	}
+
+	r.Age = time.Since(r.Timestamp)
+	if r.Age > maxAge {
+		return check.Result{}, false
+	}
+
+	return r, true
+}
+
+func (c *cache) set(r check.Result) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.results[cacheKey{checker: r.Name, ip: r.Target}] = r
+}
+
+// reset drops all cached results, e.g. because the target changed.
+func (c *cache) reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.results = map[cacheKey]check.Result{}
+}
+
+// schedule makes the scheduler keep the result of the given checker fresh.
+func (c *cache) schedule(checker check.Checker) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.scheduled[checker.Name()] = checker
+}
+
+func (c *cache) scheduledCheckers() []check.Checker {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var checkers []check.Checker
+	for _, checker := range c.scheduled {
+		checkers = append(checkers, checker)
+	}
+
+	return checkers
+}
+
+// Run performs the enabled checkers, and all checkers health checks asked
+// for, against the current target in the background until ctx is done. Their
+// results are cached and served by the health checks. Every run is delayed by
+// a random jitter so that several instances do not probe in lockstep. Run
+// returns immediately in case no check interval is configured.
+func (s *Service) Run(ctx context.Context) {
+	if s.checkInterval == 0 {
+		return
+	}
+
+	for {
+		delay := s.checkInterval
+		if s.checkJitter > 0 {
+			delay += time.Duration(rand.Int63n(int64(s.checkJitter))) // nolint:gosec
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		initializing, _, _ := s.Initializing()
+		if initializing {
+			continue
+		}
+
+		target := s.Target()
+		checkers := s.cache.scheduledCheckers()
+
+		runCtx, cancel := s.withProbeBudget(ctx)
+
+		var wg sync.WaitGroup
+		for _, ip := range target.IPs {
+			wg.Add(1)
+			go func(ip string) {
+				defer wg.Done()
+
+				results := s.runGraph(target.checkTarget(ip), checkers, func(c check.Checker) check.Result {
+					return s.runChecker(runCtx, target, ip, c)
+				})
+				// results of checks canceled because the service stops are
+				// not cached, timed out ones are
+				if ctx.Err() != nil {
+					return
+				}
+				for _, r := range results {
+					s.cache.set(r)
+					s.record(r)
+				}
+			}(ip)
+		}
+		wg.Wait()
+		cancel()
+	}
+}
+
+// cachedResult returns the result of the given checker against the given IP.
+// It is served from the cache, unless caching is disabled, ctx asks for fresh
+// results or there is no recent enough result yet. In these cases the checker
+// is performed synchronously.
+func (s *Service) cachedResult(ctx context.Context, target Target, ip string, c check.Checker) check.Result {
+	if s.checkInterval == 0 {
+		r := s.runChecker(ctx, target, ip, c)
+		if !errors.Is(ctx.Err(), context.Canceled) {
+			s.record(r)
+		}
+
+		return r
+	}
+
+	s.cache.schedule(c)
+
+	if !check.IsFresh(ctx) {
+		r, ok := s.cache.get(c.Name(), ip, staleIntervals*(s.checkInterval+s.checkJitter))
+		if ok {
+			return r
+		}
+	}
+
+	r := s.runChecker(ctx, target, ip, c)
+
+	// results of checks canceled because the client went away are not
+	// cached, so that they do not fail the following health checks
+	if !errors.Is(ctx.Err(), context.Canceled) {
+		s.cache.set(r)
+		s.record(r)
+	}
+
+	return r
+}
+
+func (s *Service) runChecker(ctx context.Context, target Target, ip string, c check.Checker) check.Result {
+	return check.Run(ctx, c, target.checkTarget(ip))
+}
@@ -0,0 +1,59 @@
+package kvm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check"
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check/checktest"
+)
+
+func Test_KVM_CachedResult(t *testing.T) {
+	tests := []struct {
+		checkInterval time.Duration
+		fresh         bool
+		expectedCount int
+	}{
+		// test 0 - served from the cache
+		{
+			checkInterval: time.Hour,
+			expectedCount: 1,
+		},
+		// test 1 - fresh results are asked for
+		{
+			checkInterval: time.Hour,
+			fresh:         true,
+			expectedCount: 3,
+		},
+		// test 2 - caching is disabled
+		{
+			checkInterval: 0,
+			expectedCount: 3,
+		},
+	}
+
+	for index, test := range tests {
+		checker := checktest.New(checktest.Config{})
+		s := newTestService(t, Config{CheckInterval: test.checkInterval}, checker)
+
+		ctx := context.Background()
+		if test.fresh {
+			ctx = check.WithFresh(ctx)
+		}
+
+		for i := 0; i < 3; i++ {
+			response, err := s.GetHealthzResponse(ctx)
+			if err != nil {
+				t.Fatalf("%d: unexpected error %#v", index, err)
+			}
+			if response.Failed {
+				t.Fatalf("%d: expected success got %q", index, response.Message)
+			}
+		}
+
+		if checker.Count() != test.expectedCount {
+			t.Fatalf("%d: expected %d checks got %d", index, test.expectedCount, checker.Count())
+		}
+	}
+}
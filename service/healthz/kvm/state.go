@@ -0,0 +1,254 @@
+package kvm
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check"
+)
+
+// states holds the health state of every checker for every IP of the target.
+// The state only changes after the configured number of consecutive successes
+// or failures, so that a single lost ping does not make the KVM unhealthy.
+type states struct {
+	failureThreshold int
+	successThreshold int
+
+	mutex  sync.Mutex
+	states map[cacheKey]checkState
+}
+
+// checkState is the state of a single checker against a single IP.
+type checkState struct {
+	state                check.State
+	consecutiveSuccesses int
+	consecutiveFailures  int
+	lastSuccess          time.Time
+}
+
+func newStates(failureThreshold int, successThreshold int) *states {
+	return &states{
+		failureThreshold: failureThreshold,
+		successThreshold: successThreshold,
+
+		states: map[cacheKey]checkState{},
+	}
+}
+
+// record updates the state of the checker and IP of the given result and
+// returns the state before and after it. Skipped results do not change the
+// state since they tell nothing about the check itself.
+//
+//   - A failure makes a check unhealthy once it failed failureThreshold times
+//     in a row. Until then a healthy check is degraded.
+//   - A success makes a check healthy once it succeeded successThreshold
+//     times in a row. Until then an unhealthy check stays unhealthy.
+//
+// Checks without any state yet are unhealthy until they become healthy, so
+// that a check which never succeeded is not mistaken for a degraded one.
+func (s *states) record(r check.Result) (check.State, check.State) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := cacheKey{checker: r.Name, ip: r.Target}
+	cs := s.states[key]
+	old := cs.state
+
+	if r.Status == check.StatusSkipped {
+		return old, old
+	}
+	if cs.state == "" {
+		cs.state = check.StateUnhealthy
+	}
+
+	if r.Failed() {
+		cs.consecutiveFailures++
+		cs.consecutiveSuccesses = 0
+
+		switch {
+		case cs.state == check.StateUnhealthy:
+		case cs.consecutiveFailures >= s.failureThreshold:
+			cs.state = check.StateUnhealthy
+		default:
+			cs.state = check.StateDegraded
+		}
+	} else {
+		cs.consecutiveSuccesses++
+		cs.consecutiveFailures = 0
+		cs.lastSuccess = r.Timestamp.Add(r.Latency)
+
+		switch {
+		case cs.state == check.StateHealthy:
+		case cs.consecutiveSuccesses >= s.successThreshold:
+			cs.state = check.StateHealthy
+		case cs.state == check.StateUnhealthy:
+		default:
+			cs.state = check.StateDegraded
+		}
+	}
+
+	s.states[key] = cs
+
+	return old, cs.state
+}
+
+// worst returns the worst state the states of the given checks against the
+// given IPs put the overall health into, according to the severities of the
+// checks. Checks without any state do not affect it.
+func (s *states) worst(checks []string, ips []string, severity func(string) check.Severity) check.State {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var worst check.State
+	for _, name := range checks {
+		for _, ip := range ips {
+			cs, ok := s.states[cacheKey{checker: name, ip: ip}]
+			if !ok {
+				continue
+			}
+
+			worst = check.WorseState(worst, severity(name).Effect(cs.state))
+		}
+	}
+
+	return worst
+}
+
+// annotate sets the state and the streaks of the checker and IP of the given
+// result.
+func (s *states) annotate(r check.Result) check.Result {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	cs, ok := s.states[cacheKey{checker: r.Name, ip: r.Target}]
+	if !ok {
+		return r
+	}
+
+	r.State = cs.state
+	r.ConsecutiveSuccesses = cs.consecutiveSuccesses
+	r.ConsecutiveFailures = cs.consecutiveFailures
+	r.LastSuccess = cs.lastSuccess
+
+	return r
+}
+
+// reset drops all states, e.g. because the target changed.
+func (s *states) reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.states = map[cacheKey]checkState{}
+}
+
+// retain drops the states of all IPs but the given ones, e.g. the ones
+// restored for a target which is not probed anymore.
+func (s *states) retain(ips []string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	keep := map[string]bool{}
+	for _, ip := range ips {
+		keep[ip] = true
+	}
+
+	for key := range s.states {
+		if !keep[key.ip] {
+			delete(s.states, key)
+		}
+	}
+}
+
+// CheckState is the state of a single checker against a single IP, as
+// persisted across restarts.
+type CheckState struct {
+	Check                string      `json:"check"`
+	Target               string      `json:"target"`
+	State                check.State `json:"state"`
+	ConsecutiveSuccesses int         `json:"consecutive_successes"`
+	ConsecutiveFailures  int         `json:"consecutive_failures"`
+	LastSuccess          time.Time   `json:"last_success"`
+}
+
+// States returns the states of all checkers against all IPs, e.g. to persist
+// them.
+func (s *Service) States() []CheckState {
+	s.states.mutex.Lock()
+	defer s.states.mutex.Unlock()
+
+	var states []CheckState
+	for key, cs := range s.states.states {
+		states = append(states, CheckState{
+			Check:                key.checker,
+			Target:               key.ip,
+			State:                cs.state,
+			ConsecutiveSuccesses: cs.consecutiveSuccesses,
+			ConsecutiveFailures:  cs.consecutiveFailures,
+			LastSuccess:          cs.lastSuccess,
+		})
+	}
+
+	sort.Slice(states, func(i, j int) bool {
+		if states[i].Check != states[j].Check {
+			return states[i].Check < states[j].Check
+		}
+		return states[i].Target < states[j].Target
+	})
+
+	return states
+}
+
+// Changed returns a channel receiving a value whenever the state of a checker
+// changed, e.g. to persist it right away. Changes happening while the last
+// one was not received yet are coalesced.
+func (s *Service) Changed() <-chan struct{} {
+	return s.changed
+}
+
+func (s *Service) notifyChanged() {
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+}
+
+// RestoreStates sets the given states, e.g. the ones persisted before a
+// restart, so that the thresholds keep counting where they stopped. States of
+// unknown checkers are ignored. States of IPs not part of the target set
+// first are dropped once it is set, see SetTarget.
+func (s *Service) RestoreStates(states []CheckState) {
+	s.states.mutex.Lock()
+	defer s.states.mutex.Unlock()
+
+	for _, cs := range states {
+		if _, err := s.registry.Enabled([]string{cs.Check}); err != nil {
+			continue
+		}
+
+		s.states.states[cacheKey{checker: cs.Check, ip: cs.Target}] = checkState{
+			state:                cs.State,
+			consecutiveSuccesses: cs.ConsecutiveSuccesses,
+			consecutiveFailures:  cs.ConsecutiveFailures,
+			lastSuccess:          cs.LastSuccess,
+		}
+
+		if !cs.LastSuccess.IsZero() {
+			check.SetLastSuccess(cs.Check, cs.Target, cs.LastSuccess)
+		}
+	}
+}
+
+// stateOf returns the state of the given annotated result. Results without a
+// state, e.g. skipped ones of a check never performed, are unhealthy in case
+// they did not succeed.
+func stateOf(r check.Result) check.State {
+	if r.State != "" {
+		return r.State
+	}
+	if r.Failed() {
+		return check.StateUnhealthy
+	}
+
+	return check.StateHealthy
+}
@@ -0,0 +1,228 @@
+package kvm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check"
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check/checktest"
+)
+
+func Test_KVM_States(t *testing.T) {
+	const (
+		ok      = check.StatusOK
+		failed  = check.StatusFailed
+		skipped = check.StatusSkipped
+	)
+
+	tests := []struct {
+		failureThreshold int
+		successThreshold int
+		statuses         []check.Status
+		expectedStates   []check.State
+	}{
+		// test 0 - thresholds of 1 follow every result
+		{
+			failureThreshold: 1,
+			successThreshold: 1,
+			statuses:         []check.Status{ok, failed, ok},
+			expectedStates:   []check.State{check.StateHealthy, check.StateUnhealthy, check.StateHealthy},
+		},
+		// test 1 - single failures are tolerated
+		{
+			failureThreshold: 3,
+			successThreshold: 1,
+			statuses:         []check.Status{ok, failed, failed, ok, failed, failed, failed, failed},
+			expectedStates: []check.State{
+				check.StateHealthy, check.StateDegraded, check.StateDegraded, check.StateHealthy,
+				check.StateDegraded, check.StateDegraded, check.StateUnhealthy, check.StateUnhealthy,
+			},
+		},
+		// test 2 - recovery requires consecutive successes
+		{
+			failureThreshold: 1,
+			successThreshold: 2,
+			statuses:         []check.Status{failed, ok, failed, ok, ok, ok},
+			expectedStates: []check.State{
+				check.StateUnhealthy, check.StateUnhealthy, check.StateUnhealthy,
+				check.StateUnhealthy, check.StateHealthy, check.StateHealthy,
+			},
+		},
+		// test 3 - checks which were never healthy are not degraded
+		{
+			failureThreshold: 3,
+			successThreshold: 2,
+			statuses:         []check.Status{failed, failed, ok, ok, failed},
+			expectedStates: []check.State{
+				check.StateUnhealthy, check.StateUnhealthy, check.StateUnhealthy, check.StateHealthy, check.StateDegraded,
+			},
+		},
+		// test 4 - skipped results do not change the state
+		{
+			failureThreshold: 2,
+			successThreshold: 1,
+			statuses:         []check.Status{ok, skipped, failed, skipped, failed},
+			expectedStates: []check.State{
+				check.StateHealthy, check.StateHealthy, check.StateDegraded, check.StateDegraded, check.StateUnhealthy,
+			},
+		},
+	}
+
+	for index, test := range tests {
+		s := newStates(test.failureThreshold, test.successThreshold)
+
+		for i, status := range test.statuses {
+			r := check.Result{Name: "ping", Target: "172.23.3.66", Status: status}
+			s.record(r)
+
+			r = s.annotate(r)
+			if r.State != test.expectedStates[i] {
+				t.Fatalf("%d: expected state %s after result %d got %s", index, test.expectedStates[i], i, r.State)
+			}
+		}
+	}
+}
+
+func Test_KVM_FailureThreshold(t *testing.T) {
+	ping := checktest.New(checktest.Config{Name: "ping"})
+	s := newTestService(t, Config{FailureThreshold: 2}, ping)
+
+	expected := []struct {
+		status              check.Status
+		failed              bool
+		state               check.State
+		consecutiveFailures int
+	}{
+		{status: check.StatusOK, failed: false, state: check.StateHealthy, consecutiveFailures: 0},
+		{status: check.StatusFailed, failed: false, state: check.StateDegraded, consecutiveFailures: 1},
+		{status: check.StatusFailed, failed: true, state: check.StateUnhealthy, consecutiveFailures: 2},
+	}
+
+	for index, e := range expected {
+		ping.SetStatus(e.status)
+
+		response, err := s.GetHealthzResponse(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if response.Failed != e.failed {
+			t.Fatalf("%d: expected failed %t got %t", index, e.failed, response.Failed)
+		}
+		if response.State != e.state {
+			t.Fatalf("%d: expected state %s got %s", index, e.state, response.State)
+		}
+		if response.Checks[0].ConsecutiveFailures != e.consecutiveFailures {
+			t.Fatalf("%d: expected %d consecutive failures got %d", index, e.consecutiveFailures, response.Checks[0].ConsecutiveFailures)
+		}
+	}
+}
+
+func Test_KVM_Severity(t *testing.T) {
+	tests := []struct {
+		severities     map[string]check.Severity
+		expectedFailed bool
+		expectedState  check.State
+	}{
+		// test 0 - failing critical checks make the kvm unhealthy
+		{
+			severities:     nil,
+			expectedFailed: true,
+			expectedState:  check.StateUnhealthy,
+		},
+		// test 1 - failing warning checks only degrade the kvm
+		{
+			severities:     map[string]check.Severity{"api": check.SeverityWarning},
+			expectedFailed: false,
+			expectedState:  check.StateDegraded,
+		},
+		// test 2 - failing info checks are only reported
+		{
+			severities:     map[string]check.Severity{"api": check.SeverityInfo},
+			expectedFailed: false,
+			expectedState:  check.StateHealthy,
+		},
+	}
+
+	for index, test := range tests {
+		s := newTestService(t, Config{},
+			checktest.New(checktest.Config{Name: "ping"}),
+			checktest.New(checktest.Config{Name: "api", Status: check.StatusFailed}),
+		)
+		for name, severity := range test.severities {
+			err := s.Registry().SetSeverity(name, severity)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		response, err := s.GetHealthzResponse(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if response.Failed != test.expectedFailed {
+			t.Fatalf("%d: expected failed %t got %t", index, test.expectedFailed, response.Failed)
+		}
+		if response.State != test.expectedState {
+			t.Fatalf("%d: expected state %s got %s", index, test.expectedState, response.State)
+		}
+		if response.Checks[1].State != check.StateUnhealthy {
+			t.Fatalf("%d: expected api check state %s got %s", index, check.StateUnhealthy, response.Checks[1].State)
+		}
+	}
+}
+
+func Test_KVM_Warning(t *testing.T) {
+	tests := []struct {
+		severities      map[string]check.Severity
+		expectedState   check.State
+		expectedMessage string
+	}{
+		// test 0 - warnings of successful checks degrade the kvm, e.g. a slow
+		// K8s API
+		{
+			severities:      nil,
+			expectedState:   check.StateDegraded,
+			expectedMessage: "Degraded by check api. slow",
+		},
+		// test 1 - warnings of info checks are only reported
+		{
+			severities:      map[string]check.Severity{"api": check.SeverityInfo},
+			expectedState:   check.StateHealthy,
+			expectedMessage: " slow",
+		},
+	}
+
+	for index, test := range tests {
+		s := newTestService(t, Config{},
+			checktest.New(checktest.Config{Name: "ping"}),
+			checktest.New(checktest.Config{Name: "api", Warning: "slow"}),
+		)
+		for name, severity := range test.severities {
+			err := s.Registry().SetSeverity(name, severity)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		response, err := s.GetHealthzResponse(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if response.Failed {
+			t.Fatalf("%d: expected response not to fail", index)
+		}
+		if response.State != test.expectedState {
+			t.Fatalf("%d: expected state %s got %s", index, test.expectedState, response.State)
+		}
+		if !strings.HasSuffix(response.Message, test.expectedMessage) {
+			t.Fatalf("%d: expected message %q got %q", index, test.expectedMessage, response.Message)
+		}
+		if response.Checks[1].State != check.StateHealthy {
+			t.Fatalf("%d: expected api check state %s got %s", index, check.StateHealthy, response.Checks[1].State)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package kvm
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/k8s-kvm-health/service/address"
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check"
+)
+
+// Target describes the KVM being probed.
+type Target struct {
+	// IPs are the addresses of the KVM which are probed. There is at most one
+	// IP per address family.
+	IPs []string
+	// MTU is the MTU of the flannel network the KVM is attached to. It is
+	// optional and only used for reporting.
+	MTU int
+	// Networks are the flannel networks the KVM is attached to. They are
+	// optional. When given, each IP must be part of the network of its family.
+	Networks []*net.IPNet
+	// Source is the file the target was derived from. It is optional and only
+	// used for reporting.
+	Source string
+
+	// LastChanged is the time the target was last changed. It is managed by
+	// the service.
+	LastChanged time.Time
+}
+
+// Equal returns true in case both targets probe the same KVM the same way,
+// regardless of when they were changed.
+func (t Target) Equal(o Target) bool {
+	return t.String() == o.String() && t.MTU == o.MTU && t.Source == o.Source
+}
+
+func (t Target) String() string {
+	var networks []string
+	for _, n := range t.Networks {
+		networks = append(networks, n.String())
+	}
+
+	return fmt.Sprintf("ips [%s] networks [%s]", strings.Join(t.IPs, ", "), strings.Join(networks, ", "))
+}
+
+func (t Target) validate() error {
+	if len(t.IPs) == 0 {
+		return microerror.Maskf(invalidConfigError, "target IPs must not be empty")
+	}
+
+	families := map[address.Family]bool{}
+	for _, s := range t.IPs {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return microerror.Maskf(invalidConfigError, "target IPs must contain valid IP addresses, got %q", s)
+		}
+
+		family := address.FamilyOf(ip)
+		if families[family] {
+			return microerror.Maskf(invalidConfigError, "target IPs must contain at most one %s address", family)
+		}
+		families[family] = true
+
+		for _, n := range t.Networks {
+			if address.FamilyOf(n.IP) == family && !n.Contains(ip) {
+				return microerror.Maskf(invalidConfigError, "target IP %s must be part of network %s", s, n)
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkTarget returns the target checkers are performed against for the given
+// IP.
+func (t Target) checkTarget(ip string) check.Target {
+	return check.Target{
+		IP:      ip,
+		Network: t.network(ip),
+		MTU:     t.MTU,
+	}
+}
+
+// network returns the flannel network the given IP is attached to, if known.
+func (t Target) network(ip string) *net.IPNet {
+	family := address.FamilyOf(net.ParseIP(ip))
+	for _, n := range t.Networks {
+		if address.FamilyOf(n.IP) == family {
+			return n
+		}
+	}
+
+	return nil
+}
+
+// Target returns the KVM currently being probed.
+func (s *Service) Target() Target {
+	return s.target.Load().(Target)
+}
+
+// SetTarget switches the probes to the given target. Health checks already
+// running finish against the previous target. Setting a target equal to the
+// current one is a no-op.
+func (s *Service) SetTarget(target Target) error {
+	err := target.validate()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	s.targetMutex.Lock()
+	defer s.targetMutex.Unlock()
+
+	current := s.Target()
+	if current.Equal(target) {
+		return nil
+	}
+
+	target.LastChanged = time.Now()
+	s.target.Store(target)
+	s.pending = pending{}
+	s.cache.reset()
+	// the states restored before the first target was set are kept for the
+	// IPs still probed
+	if len(current.IPs) == 0 {
+		s.states.retain(target.IPs)
+	} else {
+		s.states.reset()
+	}
+	setTargetInfo(target)
+
+	if len(current.IPs) == 0 {
+		s.logger.Log("level", "info", "message", fmt.Sprintf("set target to %s", target)) // nolint
+	} else {
+		s.logger.Log("level", "info", "message", fmt.Sprintf("changed target from %s to %s", current, target)) // nolint
+	}
+
+	return nil
+}
+
+// pending describes why the service is still initializing.
+type pending struct {
+	// reason tells why the target is not known yet.
+	reason string
+	// failed marks initialization as failed regardless of the startup grace
+	// period.
+	failed bool
+}
+
+// SetPending records why the target is not known yet. The reason is reported
+// while the service is initializing. failed marks the initialization as
+// failed regardless of the startup grace period. It has no effect once a
+// target was set.
+func (s *Service) SetPending(reason string, failed bool) {
+	s.targetMutex.Lock()
+	defer s.targetMutex.Unlock()
+
+	if len(s.Target().IPs) != 0 {
+		return
+	}
+
+	s.pending = pending{
+		reason: reason,
+		failed: failed,
+	}
+}
+
+// Initializing tells whether the service is still waiting for its target, and
+// if so, why. Initializing counts as failed once the startup grace period is
+// over or the initialization was marked as failed.
+func (s *Service) Initializing() (initializing bool, failed bool, reason string) {
+	s.targetMutex.Lock()
+	defer s.targetMutex.Unlock()
+
+	if len(s.Target().IPs) != 0 {
+		return false, false, ""
+	}
+
+	failed = s.pending.failed || time.Since(s.created) > s.startupGracePeriod
+
+	return true, failed, s.pending.reason
+}
@@ -0,0 +1,17 @@
+package persist
+
+import "github.com/giantswarm/microerror"
+
+var invalidConfigError = microerror.New("invalid config")
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
+var invalidFileError = microerror.New("invalid file")
+
+// IsInvalidFile asserts invalidFileError.
+func IsInvalidFile(err error) bool {
+	return microerror.Cause(err) == invalidFileError
+}
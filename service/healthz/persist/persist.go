@@ -0,0 +1,293 @@
+// Package persist saves the health state to a file and restores it at boot,
+// so that restarts of the container do not reset the thresholds of the
+// checks and the startup probe. The file is meant to live on an emptyDir or
+// hostPath volume, e.g.
+//
+//	{
+//	  "version": 1,
+//	  "saved_at": "2020-07-01T12:00:00Z",
+//	  "checks": [
+//	    {
+//	      "check": "ping",
+//	      "target": "172.23.3.66",
+//	      "state": "healthy",
+//	      "consecutive_successes": 42,
+//	      "consecutive_failures": 0,
+//	      "last_success": "2020-07-01T11:59:55Z"
+//	    }
+//	  ],
+//	  "probes": [
+//	    {
+//	      "name": "startupz",
+//	      "succeeded": "2020-07-01T10:00:00Z"
+//	    }
+//	  ]
+//	}
+package persist
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/kvm"
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/probe"
+)
+
+const (
+	// Version is the version of the file format. Files of other versions are
+	// not loaded.
+	Version = 1
+
+	defaultInterval = 10 * time.Second
+	defaultMaxAge   = 15 * time.Minute
+)
+
+// State is the content of the state file.
+type State struct {
+	Version int              `json:"version"`
+	SavedAt time.Time        `json:"saved_at"`
+	Checks  []kvm.CheckState `json:"checks"`
+	Probes  []Probe          `json:"probes"`
+}
+
+// Probe is the persisted state of a single probe.
+type Probe struct {
+	Name string `json:"name"`
+	// Succeeded is the time the probe first succeeded. It is zero in case it
+	// never succeeded.
+	Succeeded time.Time `json:"succeeded"`
+}
+
+// Config represents the configuration used to create a persist service.
+type Config struct {
+	// Dependencies.
+	KVM    *kvm.Service
+	Logger micrologger.Logger
+	Probes []*probe.Service
+
+	// Settings.
+	// File is the path of the state file.
+	File string
+	// Interval is the interval the state is saved at. Defaults to 10 seconds.
+	Interval time.Duration
+	// MaxAge is the age after which a saved state is not restored anymore,
+	// so that the KVM has to earn its health again after a long outage.
+	// Defaults to 15 minutes.
+	MaxAge time.Duration
+}
+
+// Service saves the health state of the kvm service and the probes to a file
+// and restores it.
+type Service struct {
+	// Dependencies.
+	kvm    *kvm.Service
+	logger micrologger.Logger
+	probes []*probe.Service
+
+	// Settings.
+	file     string
+	interval time.Duration
+	maxAge   time.Duration
+}
+
+// New creates a new configured persist service.
+func New(config Config) (*Service, error) {
+	// Dependencies.
+	if config.KVM == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.KVM must not be empty")
+	}
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.Logger must not be empty")
+	}
+
+	// Settings.
+	if config.File == "" {
+		return nil, microerror.Maskf(invalidConfigError, "config.File must not be empty")
+	}
+	if config.Interval < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.Interval must not be negative")
+	}
+	if config.Interval == 0 {
+		config.Interval = defaultInterval
+	}
+	if config.MaxAge < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.MaxAge must not be negative")
+	}
+	if config.MaxAge == 0 {
+		config.MaxAge = defaultMaxAge
+	}
+
+	s := &Service{
+		kvm:    config.KVM,
+		logger: config.Logger,
+		probes: config.Probes,
+
+		file:     filepath.Clean(config.File),
+		interval: config.Interval,
+		maxAge:   config.MaxAge,
+	}
+
+	return s, nil
+}
+
+// Load restores the state saved in the state file. A missing file is not an
+// error, e.g. on the very first start. A state saved longer than the max age
+// ago is discarded, so that it does not claim health the KVM has not earned
+// again.
+func (s *Service) Load() error {
+	b, err := ioutil.ReadFile(s.file)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	var state State
+	err = json.Unmarshal(b, &state)
+	if err != nil {
+		return microerror.Maskf(invalidFileError, "%s: %s", s.file, err)
+	}
+	if state.Version != Version {
+		return microerror.Maskf(invalidFileError, "%s: version must be %d, got %d", s.file, Version, state.Version)
+	}
+	if age := time.Since(state.SavedAt); age > s.maxAge {
+		s.logger.Log("level", "info", "message", fmt.Sprintf("discarding health state saved %s ago, which is older than %s", age.Round(time.Second), s.maxAge), "file", s.file) // nolint
+		return nil
+	}
+
+	s.kvm.RestoreStates(state.Checks)
+
+	succeeded := map[string]time.Time{}
+	for _, p := range state.Probes {
+		succeeded[p.Name] = p.Succeeded
+	}
+	for _, p := range s.probes {
+		p.RestoreSucceeded(succeeded[p.Name()])
+	}
+
+	return nil
+}
+
+// Save writes the current state to the state file. The file is replaced
+// atomically, so that it is never read half written.
+func (s *Service) Save() error {
+	state := State{
+		Version: Version,
+		SavedAt: time.Now().UTC(),
+		Checks:  s.kvm.States(),
+		Probes:  []Probe{},
+	}
+	if state.Checks == nil {
+		state.Checks = []kvm.CheckState{}
+	}
+	for _, p := range s.probes {
+		state.Probes = append(state.Probes, Probe{
+			Name:      p.Name(),
+			Succeeded: p.Succeeded(),
+		})
+	}
+
+	b, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = writeFile(s.file, b)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// Run saves the state every interval, and right away whenever the state of a
+// check changed or a probe first succeeded, until ctx is done. Run blocks.
+// Saving changes right away keeps them even in case the process exits before
+// the state is saved on shutdown.
+func (s *Service) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	changed := make(chan struct{}, 1)
+	for _, c := range s.changes() {
+		go func(c <-chan struct{}) {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-c:
+					select {
+					case changed <- struct{}{}:
+					default:
+					}
+				}
+			}
+		}(c)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-changed:
+		}
+
+		err := s.Save()
+		if err != nil {
+			s.logger.Log("level", "error", "message", "failed to save health state", "stack", fmt.Sprintf("%#v", err)) // nolint
+		}
+	}
+}
+
+// changes returns the channels notifying about changes of the saved state.
+func (s *Service) changes() []<-chan struct{} {
+	changes := []<-chan struct{}{s.kvm.Changed()}
+	for _, p := range s.probes {
+		changes = append(changes, p.Changed())
+	}
+
+	return changes
+}
+
+// writeFile writes the given content to a temporary file next to the given
+// path and renames it, which is atomic on POSIX file systems.
+func writeFile(path string, b []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	// the temporary file is gone after a successful rename
+	defer os.Remove(f.Name()) // nolint
+
+	_, err = f.Write(b)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = os.Chmod(f.Name(), 0644) // nolint:gosec
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = os.Rename(f.Name(), path)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
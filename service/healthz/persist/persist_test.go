@@ -0,0 +1,288 @@
+package persist
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check"
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check/checktest"
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/kvm"
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/probe"
+)
+
+func newServices(t *testing.T, file string, target kvm.Target) (*Service, *kvm.Service, *probe.Service) {
+	registry := check.NewRegistry()
+	err := registry.Register(checktest.New(checktest.Config{Name: "ping"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kvmService, err := kvm.New(kvm.Config{
+		Logger:   microloggertest.New(),
+		Registry: registry,
+
+		Checks:           []string{"ping"},
+		SuccessThreshold: 2,
+		Target:           target,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	startupService, err := probe.New(probe.Config{
+		KVM:    kvmService,
+		Logger: microloggertest.New(),
+
+		Checks:           []string{"ping"},
+		FailInitializing: true,
+		Name:             "startupz",
+		Sticky:           true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	persistService, err := New(Config{
+		KVM:    kvmService,
+		Logger: microloggertest.New(),
+		Probes: []*probe.Service{startupService},
+
+		File: file,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return persistService, kvmService, startupService
+}
+
+func Test_Persist_SaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "k8s-kvm-health-persist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "state.json")
+	target := kvm.Target{IPs: []string{"172.23.3.66"}}
+
+	// a missing file is fine on the very first start
+	saved, kvmService, startupService := newServices(t, file, target)
+	err = saved.Load()
+	if err != nil {
+		t.Fatalf("unexpected error %#v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err = kvmService.GetHealthzResponse(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = startupService.GetHealthzResponse(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = saved.Save()
+	if err != nil {
+		t.Fatalf("unexpected error %#v", err)
+	}
+
+	// after a restart the target is not known yet, the startup probe keeps
+	// succeeding nonetheless
+	restored, restoredKVMService, restoredStartupService := newServices(t, file, kvm.Target{})
+	err = restored.Load()
+	if err != nil {
+		t.Fatalf("unexpected error %#v", err)
+	}
+
+	if !restoredStartupService.Succeeded().Equal(startupService.Succeeded()) {
+		t.Fatalf("expected startup succeeded at %s got %s", startupService.Succeeded(), restoredStartupService.Succeeded())
+	}
+	response, err := restoredStartupService.GetHealthzResponse(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Failed {
+		t.Fatalf("expected startup to succeed got %#v", response)
+	}
+
+	// the streaks continue once the same target is set again
+	err = restoredKVMService.SetTarget(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	states := restoredKVMService.States()
+	expected := kvmService.States()
+	if len(states) != 1 || len(expected) != 1 {
+		t.Fatalf("expected one state got %#v", states)
+	}
+	if states[0].State != check.StateHealthy || states[0].ConsecutiveSuccesses != expected[0].ConsecutiveSuccesses || !states[0].LastSuccess.Equal(expected[0].LastSuccess) {
+		t.Fatalf("expected state %#v got %#v", expected[0], states[0])
+	}
+
+	// the states of other targets are dropped
+	_, otherKVMService, _ := newServices(t, file, kvm.Target{})
+	otherKVMService.RestoreStates(states)
+	err = otherKVMService.SetTarget(kvm.Target{IPs: []string{"172.23.3.70"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(otherKVMService.States()) != 0 {
+		t.Fatalf("expected no states got %#v", otherKVMService.States())
+	}
+}
+
+func Test_Persist_InvalidFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "k8s-kvm-health-persist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		content     string
+		expectedErr func(error) bool
+	}{
+		// test 0 - not JSON
+		{
+			content:     "state",
+			expectedErr: IsInvalidFile,
+		},
+		// test 1 - other version
+		{
+			content:     `{"version": 2}`,
+			expectedErr: IsInvalidFile,
+		},
+	}
+
+	for index, test := range tests {
+		file := filepath.Join(dir, "state.json")
+		err := ioutil.WriteFile(file, []byte(test.content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s, _, _ := newServices(t, file, kvm.Target{})
+		err = s.Load()
+		if !test.expectedErr(err) {
+			t.Fatalf("%d: expected error got %#v", index, err)
+		}
+	}
+}
+
+func Test_Persist_MaxAge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "k8s-kvm-health-persist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		age              time.Duration
+		expectedRestored bool
+	}{
+		// test 0 - saved right before a restart
+		{
+			age:              time.Minute,
+			expectedRestored: true,
+		},
+		// test 1 - saved before a long outage
+		{
+			age:              time.Hour,
+			expectedRestored: false,
+		},
+	}
+
+	for index, test := range tests {
+		file := filepath.Join(dir, "state.json")
+		state := State{
+			Version: Version,
+			SavedAt: time.Now().Add(-test.age).UTC(),
+			Checks: []kvm.CheckState{
+				{Check: "ping", Target: "172.23.3.66", State: check.StateHealthy, ConsecutiveSuccesses: 2},
+			},
+			Probes: []Probe{
+				{Name: "startupz", Succeeded: time.Now().Add(-2 * test.age).UTC()},
+			},
+		}
+		b, err := json.Marshal(state)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ioutil.WriteFile(file, b, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s, kvmService, startupService := newServices(t, file, kvm.Target{IPs: []string{"172.23.3.66"}})
+		err = s.Load()
+		if err != nil {
+			t.Fatalf("%d: unexpected error %#v", index, err)
+		}
+
+		if restored := !startupService.Succeeded().IsZero(); restored != test.expectedRestored {
+			t.Fatalf("%d: expected startup succeeded restored %t got %s", index, test.expectedRestored, startupService.Succeeded())
+		}
+		if restored := len(kvmService.States()) != 0; restored != test.expectedRestored {
+			t.Fatalf("%d: expected states restored %t got %#v", index, test.expectedRestored, kvmService.States())
+		}
+	}
+}
+
+func Test_Persist_SaveOnChange(t *testing.T) {
+	dir, err := ioutil.TempDir("", "k8s-kvm-health-persist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "state.json")
+	target := kvm.Target{IPs: []string{"172.23.3.66"}}
+
+	// the default interval of 10 seconds is not reached during the test, the
+	// state is only saved because it changed
+	persistService, kvmService, startupService := newServices(t, file, target)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go persistService.Run(ctx)
+
+	for i := 0; i < 2; i++ {
+		_, err = kvmService.GetHealthzResponse(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = startupService.GetHealthzResponse(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var state State
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		b, err := ioutil.ReadFile(file)
+		if err == nil {
+			err = json.Unmarshal(b, &state)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if len(state.Checks) == 1 && state.Checks[0].State == check.StateHealthy && len(state.Probes) == 1 && !state.Probes[0].Succeeded.IsZero() {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected healthy check and succeeded startup probe to be saved got %#v", state)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
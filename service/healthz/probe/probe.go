@@ -0,0 +1,221 @@
+// Package probe implements the health checks backing the Kubernetes liveness,
+// readiness and startup probes. Each probe performs a configurable subset of
+// the kvm checks.
+package probe
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/giantswarm/microendpoint/service/healthz"
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check"
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/kvm"
+)
+
+const (
+	// SuccessMessage is the message returned in case no checks are configured.
+	SuccessMessage = "Service up."
+)
+
+// Config represents the configuration used to create a probe service.
+type Config struct {
+	// Dependencies.
+	KVM    *kvm.Service
+	Logger micrologger.Logger
+
+	// Settings.
+	// Checks are the names of the checkers of the kvm registry performed by
+	// the probe. Without checks the probe only tells that the process is up.
+	Checks      []string
+	Description string
+	// FailInitializing makes the probe fail while the kvm service is
+	// initializing, regardless of its startup grace period.
+	FailInitializing bool
+	Name             string
+	// Sticky makes the probe succeed forever once it was healthy.
+	Sticky bool
+}
+
+// Service implements the healthz service interface for a single probe.
+type Service struct {
+	// Dependencies.
+	kvm    *kvm.Service
+	logger micrologger.Logger
+
+	// Internals.
+	changed   chan struct{}
+	mutex     sync.Mutex
+	succeeded time.Time
+
+	// Settings.
+	checks           []string
+	description      string
+	failInitializing bool
+	name             string
+	sticky           bool
+}
+
+// New creates a new configured probe service.
+func New(config Config) (*Service, error) {
+	// Dependencies.
+	if config.KVM == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.KVM must not be empty")
+	}
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.Logger must not be empty")
+	}
+
+	// Settings.
+	_, err := config.KVM.Registry().Enabled(config.Checks)
+	if err != nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.Checks: %s", err)
+	}
+	if config.Name == "" {
+		return nil, microerror.Maskf(invalidConfigError, "config.Name must not be empty")
+	}
+
+	newService := &Service{
+		// Dependencies.
+		kvm:    config.KVM,
+		logger: config.Logger,
+
+		// Internals.
+		changed: make(chan struct{}, 1),
+
+		// Settings.
+		checks:           config.Checks,
+		description:      config.Description,
+		failInitializing: config.FailInitializing,
+		name:             config.Name,
+		sticky:           config.Sticky,
+	}
+
+	return newService, nil
+}
+
+// Checks returns the names of the checks performed by the probe.
+func (s *Service) Checks() []string {
+	return s.checks
+}
+
+// Name returns the name of the probe.
+func (s *Service) Name() string {
+	return s.name
+}
+
+// Succeeded returns the time the probe first succeeded. It is zero in case
+// the probe never succeeded.
+func (s *Service) Succeeded() time.Time {
+	return s.getSucceeded()
+}
+
+// Changed returns a channel receiving a value once the probe first
+// succeeded, e.g. to persist it right away.
+func (s *Service) Changed() <-chan struct{} {
+	return s.changed
+}
+
+// RestoreSucceeded sets the time the probe first succeeded, e.g. the one
+// persisted before a restart, so that sticky probes keep succeeding. Earlier
+// times are kept.
+func (s *Service) RestoreSucceeded(succeeded time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if succeeded.IsZero() {
+		return
+	}
+	if s.succeeded.IsZero() || succeeded.Before(s.succeeded) {
+		s.succeeded = succeeded
+	}
+}
+
+// GetHealthz performs the checks of the probe.
+func (s *Service) GetHealthz(ctx context.Context) (healthz.Response, error) {
+	response, err := s.GetHealthzResponse(ctx)
+	if err != nil {
+		return healthz.Response{}, microerror.Mask(err)
+	}
+
+	return response.Response, nil
+}
+
+// GetHealthzResponse works like GetHealthz but also returns the results of the
+// single checks.
+func (s *Service) GetHealthzResponse(ctx context.Context) (check.Response, error) {
+	response := check.Response{
+		Response: healthz.Response{
+			Description: s.description,
+			Name:        s.name,
+		},
+	}
+
+	if s.sticky {
+		succeeded := s.getSucceeded()
+		if !succeeded.IsZero() {
+			response.Message = fmt.Sprintf("Healthy since %s.", succeeded.Format(time.RFC3339))
+			response.State = check.StateHealthy
+			return response, nil
+		}
+	}
+
+	if len(s.checks) == 0 {
+		response.Message = SuccessMessage
+		response.State = check.StateHealthy
+		s.setSucceeded()
+		return response, nil
+	}
+
+	if s.failInitializing {
+		initializing, _, reason := s.kvm.Initializing()
+		if initializing {
+			response.Failed = true
+			response.Message = fmt.Sprintf("%s %s", kvm.InitializingMessage, reason)
+			response.State = check.StateInitializing
+			return response, nil
+		}
+	}
+
+	kvmResponse, err := s.kvm.GetHealthzChecks(ctx, s.checks)
+	if err != nil {
+		return check.Response{}, microerror.Mask(err)
+	}
+
+	response.Failed = kvmResponse.Failed
+	response.Message = kvmResponse.Message
+	response.State = kvmResponse.State
+	response.Checks = kvmResponse.Checks
+
+	// degraded responses do not fail but must not latch a sticky probe
+	if response.State == check.StateHealthy {
+		s.setSucceeded()
+	}
+
+	return response, nil
+}
+
+func (s *Service) getSucceeded() time.Time {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.succeeded
+}
+
+func (s *Service) setSucceeded() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.succeeded.IsZero() {
+		s.succeeded = time.Now()
+
+		select {
+		case s.changed <- struct{}{}:
+		default:
+		}
+	}
+}
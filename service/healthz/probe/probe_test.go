@@ -0,0 +1,191 @@
+package probe
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check"
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check/checktest"
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/kvm"
+)
+
+// testCheckName is the name of the checker registered by newTestKVM by
+// default.
+const testCheckName = "test"
+
+// newTestKVM creates a kvm service with a registry of the given checkers, or
+// of a single successful checker named testCheckName in case none are given.
+func newTestKVM(t *testing.T, config kvm.Config, checkers ...check.Checker) *kvm.Service {
+	if len(checkers) == 0 {
+		checkers = []check.Checker{checktest.New(checktest.Config{Name: testCheckName})}
+	}
+
+	registry := check.NewRegistry()
+	for _, c := range checkers {
+		err := registry.Register(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config.Logger = microloggertest.New()
+	config.Registry = registry
+
+	kvmService, err := kvm.New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return kvmService
+}
+
+func Test_Probe_GetHealthz(t *testing.T) {
+	tests := []struct {
+		checks           []string
+		failInitializing bool
+		expectedFailed   bool
+		expectedMessage  string
+		expectedState    check.State
+	}{
+		// test 0 - no checks, the process is up
+		{
+			checks:          nil,
+			expectedFailed:  false,
+			expectedMessage: SuccessMessage,
+			expectedState:   check.StateHealthy,
+		},
+		// test 1 - initializing within the grace period
+		{
+			checks:          []string{testCheckName},
+			expectedFailed:  false,
+			expectedMessage: kvm.InitializingMessage,
+			expectedState:   check.StateInitializing,
+		},
+		// test 2 - initializing fails regardless of the grace period
+		{
+			checks:           []string{testCheckName},
+			failInitializing: true,
+			expectedFailed:   true,
+			expectedMessage:  kvm.InitializingMessage,
+			expectedState:    check.StateInitializing,
+		},
+		// test 3 - no checks are not affected by initializing
+		{
+			checks:           nil,
+			failInitializing: true,
+			expectedFailed:   false,
+			expectedMessage:  SuccessMessage,
+			expectedState:    check.StateHealthy,
+		},
+	}
+
+	for index, test := range tests {
+		s, err := New(Config{
+			KVM:    newTestKVM(t, kvm.Config{StartupGracePeriod: time.Hour}),
+			Logger: microloggertest.New(),
+
+			Checks:           test.checks,
+			FailInitializing: test.failInitializing,
+			Name:             "test",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		response, err := s.GetHealthzResponse(context.Background())
+		if err != nil {
+			t.Fatalf("%d: unexpected error %#v", index, err)
+		}
+		if response.Failed != test.expectedFailed {
+			t.Fatalf("%d: expected failed %t got %t", index, test.expectedFailed, response.Failed)
+		}
+		if !strings.HasPrefix(response.Message, test.expectedMessage) {
+			t.Fatalf("%d: expected message prefix %q got %q", index, test.expectedMessage, response.Message)
+		}
+		if response.State != test.expectedState {
+			t.Fatalf("%d: expected state %s got %s", index, test.expectedState, response.State)
+		}
+	}
+}
+
+func Test_Probe_GetHealthz_Sticky(t *testing.T) {
+	s, err := New(Config{
+		KVM:    newTestKVM(t, kvm.Config{}),
+		Logger: microloggertest.New(),
+
+		Name:   "test",
+		Sticky: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// without checks the first call succeeds, afterwards the probe must keep
+	// succeeding even once checks would fail
+	_, err = s.GetHealthz(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.checks = []string{testCheckName}
+	s.failInitializing = true
+
+	response, err := s.GetHealthz(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Failed {
+		t.Fatalf("expected sticky probe to succeed, got %q", response.Message)
+	}
+}
+
+func Test_Probe_GetHealthz_StickyFailureThreshold(t *testing.T) {
+	checker := checktest.New(checktest.Config{Name: testCheckName, Status: check.StatusFailed})
+	s, err := New(Config{
+		KVM: newTestKVM(t, kvm.Config{
+			FailureThreshold: 3,
+			Target:           kvm.Target{IPs: []string{"172.23.3.66"}},
+		}, checker),
+		Logger: microloggertest.New(),
+
+		Checks:           []string{testCheckName},
+		FailInitializing: true,
+		Name:             "test",
+		Sticky:           true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a check which never succeeded is unhealthy right away and must not
+	// latch the probe, regardless of the failure threshold
+	response, err := s.GetHealthzResponse(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !response.Failed || response.State != check.StateUnhealthy {
+		t.Fatalf("expected unhealthy response, got state %s failed %t", response.State, response.Failed)
+	}
+
+	checker.SetStatus(check.StatusOK)
+	_, err = s.GetHealthzResponse(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// once healthy the probe keeps succeeding
+	checker.SetStatus(check.StatusFailed)
+	response, err = s.GetHealthzResponse(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Failed {
+		t.Fatalf("expected sticky probe to succeed, got %q", response.Message)
+	}
+	if checker.Count() != 2 {
+		t.Fatalf("expected 2 checks got %d", checker.Count())
+	}
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/k8s-kvm-health/service/healthz"
+)
+
+const (
+	// defaultCertExpiryWarning is the time before the K8s API certificate
+	// expires from which on the api check warns.
+	defaultCertExpiryWarning = 30 * 24 * time.Hour
+	// defaultLatencyWarning is the time from which on the api check warns
+	// that the K8s API is slow, which degrades the health.
+	defaultLatencyWarning = 1 * time.Second
+)
+
+// setHTTPConfig parses the flags of the kubelet and K8s API checks into the
+// given healthz config. The values are validated by the checks.
+func (c *Config) setHTTPConfig(healthzConfig *healthz.Config) error {
+	f := c.Flag.Service
+
+	var err error
+
+	healthzConfig.API.ExpectedBody = f.APIExpectedBody
+	healthzConfig.API.ExpectedStatusCodes, err = parseStatusCodes("APIExpectedStatus", f.APIExpectedStatus)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	healthzConfig.API.Host = f.APIHost
+	healthzConfig.API.LatencyWarning, err = parseDuration("APILatencyWarning", f.APILatencyWarning, defaultLatencyWarning)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	healthzConfig.API.Path = f.APIPath
+	healthzConfig.API.Port, err = parseInt("APIPort", f.APIPort)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	healthzConfig.API.Scheme = f.APIScheme
+	healthzConfig.API.TokenFile = f.APITokenFile
+	healthzConfig.API.Verbose = f.APIVerbose == "true"
+
+	healthzConfig.API.TLS.CAFile = f.APICAFile
+	healthzConfig.API.TLS.CertFile = f.APIClientCertFile
+	healthzConfig.API.TLS.KeyFile = f.APIClientKeyFile
+	healthzConfig.API.TLS.ServerName = f.APIServerName
+	healthzConfig.API.TLS.ExpiryWarning, err = parseDuration("APICertExpiryWarning", f.APICertExpiryWarning, defaultCertExpiryWarning)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	healthzConfig.Kubelet.ExpectedBody = f.KubeletExpectedBody
+	healthzConfig.Kubelet.ExpectedStatusCodes, err = parseStatusCodes("KubeletExpectedStatus", f.KubeletExpectedStatus)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	healthzConfig.Kubelet.Host = f.KubeletHost
+	healthzConfig.Kubelet.Path = f.KubeletPath
+	healthzConfig.Kubelet.Port, err = parseInt("KubeletPort", f.KubeletPort)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	healthzConfig.Kubelet.Scheme = f.KubeletScheme
+
+	return nil
+}
+
+// parseStatusCodes parses the comma separated list of HTTP status codes of the
+// flag with the given name. An empty value results in no status codes, so that
+// the check applies its default.
+func parseStatusCodes(name string, value string) ([]int, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var codes []int
+	for _, s := range strings.Split(value, ",") {
+		code, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return nil, microerror.Maskf(invalidConfigError, "config.Flag.Service.%s must be a comma separated list of status codes, got %q", name, value)
+		}
+
+		codes = append(codes, code)
+	}
+
+	return codes, nil
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/k8s-kvm-health/service/healthz"
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check/ping"
+)
+
+// setPingConfig parses the ping flags into the given healthz config. Empty
+// flags are left at zero, so that the ping check applies its defaults. The
+// values are validated by the ping check.
+func (c *Config) setPingConfig(healthzConfig *healthz.Config) error {
+	f := c.Flag.Service
+
+	var err error
+
+	healthzConfig.PingCount, err = parseInt("PingCount", f.PingCount)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	healthzConfig.PingInterval, err = parseDuration("PingInterval", f.PingInterval, 0)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	healthzConfig.PingTimeout, err = parseDuration("PingTimeout", f.PingTimeout, 0)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	healthzConfig.PingSize, err = parseInt("PingSize", f.PingSize)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	healthzConfig.PingMode = ping.Mode(f.PingMode)
+	healthzConfig.PingTCPPort, err = parseInt("PingTCPPort", f.PingTCPPort)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	// the ping check disables the tcp fallback for negative ports, while zero
+	// means its default, for the flag it is the other way round
+	if f.PingTCPPort == "" {
+		healthzConfig.PingTCPPort = ping.DefaultTCPPort
+	} else if healthzConfig.PingTCPPort == 0 {
+		healthzConfig.PingTCPPort = -1
+	}
+	healthzConfig.PingMaxLoss, err = parseFloat("PingMaxLoss", f.PingMaxLoss)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	healthzConfig.PingMaxAvgRTT, err = parseDuration("PingMaxAvgRTT", f.PingMaxAvgRTT, 0)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	healthzConfig.PingMaxP99RTT, err = parseDuration("PingMaxP99RTT", f.PingMaxP99RTT, 0)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
@@ -1,15 +1,23 @@
 package service
 
 import (
+	"context"
+	"fmt"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/giantswarm/microendpoint/service/version"
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger"
 
 	"github.com/giantswarm/k8s-kvm-health/flag"
+	"github.com/giantswarm/k8s-kvm-health/service/address"
+	"github.com/giantswarm/k8s-kvm-health/service/flannel"
 	"github.com/giantswarm/k8s-kvm-health/service/healthz"
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check/definition"
+	"github.com/giantswarm/k8s-kvm-health/service/watcher"
 )
 
 // Config represents the configuration used to create a new service.
@@ -24,6 +32,11 @@ type Config struct {
 	GitCommit   string
 	Name        string
 	Source      string
+
+	// Internals.
+	addressConfig address.Config
+	flannelEnv    flannel.Env
+	flannelWait   waitConfig
 }
 
 // DefaultConfig provides a default configuration to create a new service by
@@ -53,24 +66,166 @@ func New(config Config) (*Service, error) {
 		return nil, microerror.Maskf(invalidConfigError, "config.Logger must not be empty")
 	}
 
+	switch config.Flag.Service.IPFamily {
+	case "", ipFamilyIPv4, ipFamilyIPv6, ipFamilyDual:
+	default:
+		return nil, microerror.Maskf(invalidConfigError, "config.Flag.Service.IPFamily must be one of %q, %q or %q, got %q", ipFamilyIPv4, ipFamilyIPv6, ipFamilyDual, config.Flag.Service.IPFamily)
+	}
+
 	var err error
 
-	// load kvm network configuration
-	err = config.LoadFlannelConfig()
+	watchPollInterval, err := parseDuration("WatchPollInterval", config.Flag.Service.WatchPollInterval, 0)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	if watchPollInterval < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.Flag.Service.WatchPollInterval must not be negative")
+	}
+
+	config.flannelWait, err = config.newWaitConfig()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	startupGracePeriod, err := parseDuration("StartupGracePeriod", config.Flag.Service.StartupGracePeriod, defaultStartupGracePeriod)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	if startupGracePeriod < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.Flag.Service.StartupGracePeriod must not be negative")
+	}
+
+	checkInterval, err := parseDuration("CheckInterval", config.Flag.Service.CheckInterval, defaultCheckInterval)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	if checkInterval < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.Flag.Service.CheckInterval must not be negative")
+	}
+
+	checkJitter, err := parseDuration("CheckJitter", config.Flag.Service.CheckJitter, defaultCheckJitter)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	if checkJitter < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.Flag.Service.CheckJitter must not be negative")
+	}
+
+	stateSaveInterval, err := parseDuration("StateSaveInterval", config.Flag.Service.StateSaveInterval, defaultStateSaveInterval)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	if stateSaveInterval <= 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.Flag.Service.StateSaveInterval must be positive")
+	}
+
+	stateMaxAge, err := parseDuration("StateMaxAge", config.Flag.Service.StateMaxAge, defaultStateMaxAge)
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
+	if stateMaxAge <= 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.Flag.Service.StateMaxAge must be positive")
+	}
+
+	probeBudget, err := parseDuration("ProbeBudget", config.Flag.Service.ProbeBudget, defaultProbeBudget)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	if probeBudget < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.Flag.Service.ProbeBudget must not be negative")
+	}
 
+	failureThreshold, err := parseInt("FailureThreshold", config.Flag.Service.FailureThreshold)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	successThreshold, err := parseInt("SuccessThreshold", config.Flag.Service.SuccessThreshold)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	historySize, err := parseInt("HistorySize", config.Flag.Service.HistorySize)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	config.addressConfig, err = config.newAddressConfig()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	// the kvm network configuration is loaded in the background once the
+	// service boots, until then the healthz service reports initializing
 	var healthzService *healthz.Service
 	{
 		healthzConfig := healthz.Config{
-			CheckAPI:  false,
-			IPAddress: config.Flag.Service.IPAddress,
-			Logger:    config.Logger,
+			Logger: config.Logger,
+
+			CheckInterval:      checkInterval,
+			CheckJitter:        checkJitter,
+			FailureThreshold:   failureThreshold,
+			HistorySize:        historySize,
+			ProbeBudget:        probeBudget,
+			StartupGracePeriod: startupGracePeriod,
+			StateFile:          config.Flag.Service.StateFile,
+			StateMaxAge:        stateMaxAge,
+			StateSaveInterval:  stateSaveInterval,
+			SuccessThreshold:   successThreshold,
 		}
 
+		// the enabled checks default to ping and kubelet, plus the k8s api in
+		// case it is requested
+		defaultChecks := []string{healthz.CheckPing, healthz.CheckKubelet}
 		if config.Flag.Service.CheckAPI == strings.ToLower("true") {
-			healthzConfig.CheckAPI = true
+			defaultChecks = append(defaultChecks, healthz.CheckAPI)
+		}
+
+		// the checks declared in the checks file are enabled by default as
+		// well, after the built-in ones
+		if config.Flag.Service.ChecksFile != "" {
+			healthzConfig.Definitions, err = definition.Load(config.Flag.Service.ChecksFile)
+			if err != nil {
+				return nil, microerror.Maskf(invalidConfigError, "config.Flag.Service.ChecksFile: %s", err)
+			}
+			defaultChecks = append(defaultChecks, definition.Names(healthzConfig.Definitions)...)
+		}
+
+		err = config.setHTTPConfig(&healthzConfig)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		err = config.setPingConfig(&healthzConfig)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		err = config.setSeverityConfig(&healthzConfig)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		healthzConfig.Checks, err = parseChecks("Checks", config.Flag.Service.Checks, defaultChecks)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		healthzConfig.Dependencies, err = parseDependencies("CheckDependencies", config.Flag.Service.CheckDependencies)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		// readiness and startup default to the enabled checks, liveness does
+		// not depend on the kvm by default
+		healthzConfig.LivenessChecks, err = parseChecks("LivezChecks", config.Flag.Service.LivezChecks, nil)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		healthzConfig.ReadinessChecks, err = parseChecks("ReadyzChecks", config.Flag.Service.ReadyzChecks, healthzConfig.Checks)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		healthzConfig.StartupChecks, err = parseChecks("StartupzChecks", config.Flag.Service.StartupzChecks, healthzConfig.Checks)
+		if err != nil {
+			return nil, microerror.Mask(err)
 		}
 
 		healthzService, err = healthz.New(healthzConfig)
@@ -94,6 +249,31 @@ func New(config Config) (*Service, error) {
 		}
 	}
 
+	// watch the flannel file, and the address file if the address strategy
+	// reads one, so that the kvm target follows changes at runtime
+	var watchers []*watcher.Watcher
+	{
+		files := []string{config.Flag.Service.FlannelFile}
+		if config.addressConfig.File != "" {
+			files = append(files, config.addressConfig.File)
+		}
+
+		for _, file := range files {
+			watcherConfig := watcher.Config{
+				Logger: config.Logger,
+
+				File:         file,
+				PollInterval: watchPollInterval,
+			}
+
+			w, err := watcher.New(watcherConfig)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+			watchers = append(watchers, w)
+		}
+	}
+
 	newService := &Service{
 		// Dependencies.
 		Healthz: healthzService,
@@ -101,6 +281,9 @@ func New(config Config) (*Service, error) {
 
 		// Internals
 		bootOnce: sync.Once{},
+		config:   config,
+		logger:   config.Logger,
+		watchers: watchers,
 	}
 
 	return newService, nil
@@ -113,4 +296,215 @@ type Service struct {
 
 	// Internals.
 	bootOnce sync.Once
+	config   Config
+	logger   micrologger.Logger
+	watchers []*watcher.Watcher
+}
+
+// Boot starts the background work of the service until ctx is done. It does
+// not block. The kvm target is derived from the flannel file as soon as the
+// file can be used. Afterwards the flannel file is watched and the kvm target
+// is derived again whenever it changes. The kvm checks are performed in the
+// background at the configured check interval.
+func (s *Service) Boot(ctx context.Context) {
+	s.bootOnce.Do(func() {
+		go s.Healthz.KVM.Run(ctx)
+
+		if s.Healthz.Persist != nil {
+			go s.Healthz.Persist.Run(ctx)
+		}
+
+		go func() {
+			s.initKVMTarget(ctx)
+
+			for _, w := range s.watchers {
+				go w.Run(ctx, s.reloadKVMTarget)
+			}
+		}()
+	})
+}
+
+// LoadState restores the health state persisted before a restart, in case a
+// state file is configured. It must be called before Boot.
+func (s *Service) LoadState() error {
+	if s.Healthz.Persist == nil {
+		return nil
+	}
+
+	err := s.Healthz.Persist.Load()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// SaveState persists the current health state, in case a state file is
+// configured.
+func (s *Service) SaveState() error {
+	if s.Healthz.Persist == nil {
+		return nil
+	}
+
+	err := s.Healthz.Persist.Save()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// initKVMTarget waits for the flannel file and sets the initial kvm target.
+// While waiting, the reason is reported by the kvm health check. In case
+// waiting times out, initialization is marked as failed. The watchers may
+// still set a target later on.
+func (s *Service) initKVMTarget(ctx context.Context) {
+	kvmService := s.Healthz.KVM
+
+	onWaiting := func(reason string) {
+		kvmService.SetPending(reason, false)
+	}
+
+	err := s.config.LoadFlannelConfig(ctx, onWaiting)
+	if IsInvalidFlannelFile(err) {
+		_ = s.logger.Log("level", "error", "message", "failed to load flannel file", "stack", fmt.Sprintf("%#v", err))
+		kvmService.SetPending(err.Error(), true)
+		return
+	} else if err != nil {
+		// ctx is done, the service is shutting down
+		return
+	}
+
+	target, err := s.config.kvmTarget(s.config.flannelEnv, s.config.Flag.Service.IPAddress, s.config.Flag.Service.IPv6Address)
+	if err == nil {
+		err = kvmService.SetTarget(target)
+	}
+	if err != nil {
+		_ = s.logger.Log("level", "error", "message", "failed to set kvm target", "stack", fmt.Sprintf("%#v", err))
+		kvmService.SetPending(err.Error(), true)
+		return
+	}
+}
+
+// reloadKVMTarget derives the kvm target from the current content of the
+// flannel file and switches the kvm health check over to it. The current
+// target is kept in case the file cannot be used.
+func (s *Service) reloadKVMTarget() {
+	target, err := s.config.reloadKVMTarget()
+	if err != nil {
+		_ = s.logger.Log("level", "error", "message", "failed to reload kvm target, keeping current target", "stack", fmt.Sprintf("%#v", err))
+		return
+	}
+
+	err = s.Healthz.KVM.SetTarget(target)
+	if err != nil {
+		_ = s.logger.Log("level", "error", "message", "failed to set kvm target, keeping current target", "stack", fmt.Sprintf("%#v", err))
+		return
+	}
+}
+
+const (
+	checksNone                = "none"
+	defaultCheckInterval      = 10 * time.Second
+	defaultCheckJitter        = 1 * time.Second
+	defaultProbeBudget        = 10 * time.Second
+	defaultStartupGracePeriod = 100 * time.Second
+	defaultStateMaxAge        = 15 * time.Minute
+	defaultStateSaveInterval  = 10 * time.Second
+)
+
+// parseDuration parses the duration flag with the given name. An empty value
+// results in the given default.
+func parseDuration(name string, value string, defaultValue time.Duration) (time.Duration, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, microerror.Maskf(invalidConfigError, "config.Flag.Service.%s must be a duration, got %q", name, value)
+	}
+
+	return d, nil
+}
+
+// parseInt parses the integer flag with the given name. An empty value results
+// in zero.
+func parseInt(name string, value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, microerror.Maskf(invalidConfigError, "config.Flag.Service.%s must be an integer, got %q", name, value)
+	}
+
+	return i, nil
+}
+
+// parseFloat parses the floating point number flag with the given name. An
+// empty value results in zero.
+func parseFloat(name string, value string) (float64, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, microerror.Maskf(invalidConfigError, "config.Flag.Service.%s must be a number, got %q", name, value)
+	}
+
+	return f, nil
+}
+
+// parseChecks parses the comma separated list of check names of the flag with
+// the given name. An empty value results in the given default, "none" results
+// in no checks. Whether the checks exist is validated by the healthz service.
+func parseChecks(name string, value string, defaultValue []string) ([]string, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	if value == checksNone {
+		return nil, nil
+	}
+
+	var checks []string
+	for _, c := range strings.Split(value, ",") {
+		c = strings.TrimSpace(c)
+		if c == "" {
+			return nil, microerror.Maskf(invalidConfigError, "config.Flag.Service.%s must be %q or a comma separated list of checks, got %q", name, checksNone, value)
+		}
+
+		checks = append(checks, c)
+	}
+
+	return checks, nil
+}
+
+// parseDependencies parses the dependencies flag with the given name, e.g.
+// kubelet=ping;api=ping,kubelet, into the names of the checks each check
+// depends on.
+func parseDependencies(name string, value string) (map[string][]string, error) {
+	dependencies := map[string][]string{}
+	if value == "" {
+		return dependencies, nil
+	}
+
+	for _, entry := range strings.Split(value, ";") {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, microerror.Maskf(invalidConfigError, "config.Flag.Service.%s must be a semicolon separated list of check=dependencies, got %q", name, value)
+		}
+
+		checks, err := parseChecks(name, strings.TrimSpace(parts[1]), nil)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		checkName := strings.TrimSpace(parts[0])
+		dependencies[checkName] = append(dependencies[checkName], checks...)
+	}
+
+	return dependencies, nil
 }
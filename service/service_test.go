@@ -0,0 +1,107 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+
+	"github.com/giantswarm/k8s-kvm-health/flag"
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check/httpget"
+)
+
+func Test_Service_New_CheckerSettings(t *testing.T) {
+	tests := []struct {
+		setFlags    func(f *flag.Flag)
+		expectedErr func(error) bool
+	}{
+		// test 0 - defaults
+		{
+			setFlags: func(f *flag.Flag) {},
+		},
+		// test 1 - valid settings
+		{
+			setFlags: func(f *flag.Flag) {
+				f.Service.APIExpectedStatus = "200, 401"
+				f.Service.APIPath = "/readyz"
+				f.Service.APIPort = "6443"
+				f.Service.KubeletHost = "localhost"
+				f.Service.KubeletPort = "10250"
+				f.Service.KubeletScheme = "https"
+			},
+		},
+		// test 2 - port is not a number
+		{
+			setFlags: func(f *flag.Flag) {
+				f.Service.KubeletPort = "kubelet"
+			},
+			expectedErr: IsInvalidConfig,
+		},
+		// test 3 - port out of range
+		{
+			setFlags: func(f *flag.Flag) {
+				f.Service.APIPort = "70000"
+			},
+			expectedErr: httpget.IsInvalidConfig,
+		},
+		// test 4 - path without leading slash
+		{
+			setFlags: func(f *flag.Flag) {
+				f.Service.APIPath = "healthz"
+			},
+			expectedErr: httpget.IsInvalidConfig,
+		},
+		// test 5 - unknown scheme
+		{
+			setFlags: func(f *flag.Flag) {
+				f.Service.KubeletScheme = "ftp"
+			},
+			expectedErr: httpget.IsInvalidConfig,
+		},
+		// test 6 - status codes not a list of numbers
+		{
+			setFlags: func(f *flag.Flag) {
+				f.Service.APIExpectedStatus = "200,ok"
+			},
+			expectedErr: IsInvalidConfig,
+		},
+		// test 7 - unknown status code
+		{
+			setFlags: func(f *flag.Flag) {
+				f.Service.KubeletExpectedStatus = "700"
+			},
+			expectedErr: httpget.IsInvalidConfig,
+		},
+		// test 8 - client certificate without key
+		{
+			setFlags: func(f *flag.Flag) {
+				f.Service.APIClientCertFile = "/etc/kubernetes/ssl/client.pem"
+			},
+			expectedErr: httpget.IsInvalidConfig,
+		},
+	}
+
+	for index, test := range tests {
+		// the flags are read from the environment, unset ones are empty
+		f := &flag.Flag{}
+		f.Service.FlannelFile = "/run/flannel/networks/br-1a2b3c.env"
+		test.setFlags(f)
+
+		config := DefaultConfig()
+		config.Flag = f
+		config.Logger = microloggertest.New()
+
+		config.Description = "test"
+		config.GitCommit = "test"
+		config.Name = "test"
+		config.Source = "test"
+
+		_, err := New(config)
+		if test.expectedErr != nil {
+			if !test.expectedErr(err) {
+				t.Fatalf("%d: unexpected error %#v", index, err)
+			}
+		} else if err != nil {
+			t.Fatalf("%d: unexpected error %#v", index, err)
+		}
+	}
+}
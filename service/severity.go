@@ -0,0 +1,101 @@
+package service
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/k8s-kvm-health/service/healthz"
+	"github.com/giantswarm/k8s-kvm-health/service/healthz/check"
+)
+
+// setSeverityConfig parses the flags of the check severities and of the
+// status codes of the health endpoints into the given healthz config. Whether
+// the checks exist is validated by the healthz service.
+func (c *Config) setSeverityConfig(healthzConfig *healthz.Config) error {
+	f := c.Flag.Service
+
+	var err error
+
+	healthzConfig.Severities, err = parseSeverities("CheckSeverities", f.CheckSeverities)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	healthzConfig.StatusCodes.Healthz, err = parseStateStatusCodes("HealthzStatusCodes", f.HealthzStatusCodes)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	healthzConfig.StatusCodes.Livez, err = parseStateStatusCodes("LivezStatusCodes", f.LivezStatusCodes)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	healthzConfig.StatusCodes.Readyz, err = parseStateStatusCodes("ReadyzStatusCodes", f.ReadyzStatusCodes)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	healthzConfig.StatusCodes.Startupz, err = parseStateStatusCodes("StartupzStatusCodes", f.StartupzStatusCodes)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// parseSeverities parses the severities flag with the given name, e.g.
+// api=warning,ssh=info, into the severity of each check.
+func parseSeverities(name string, value string) (map[string]check.Severity, error) {
+	severities := map[string]check.Severity{}
+	if value == "" {
+		return severities, nil
+	}
+
+	for _, entry := range strings.Split(value, ",") {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, microerror.Maskf(invalidConfigError, "config.Flag.Service.%s must be a comma separated list of check=severity, got %q", name, value)
+		}
+
+		severity, err := check.ParseSeverity(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, microerror.Maskf(invalidConfigError, "config.Flag.Service.%s: %s", name, err)
+		}
+
+		severities[strings.TrimSpace(parts[0])] = severity
+	}
+
+	return severities, nil
+}
+
+// parseStateStatusCodes parses the status codes flag of a health endpoint with
+// the given name, e.g. degraded=207,unhealthy=503. States not given keep their
+// default status code.
+func parseStateStatusCodes(name string, value string) (check.StatusCodes, error) {
+	codes := check.DefaultStatusCodes()
+	if value == "" {
+		return codes, nil
+	}
+
+	for _, entry := range strings.Split(value, ",") {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			return nil, microerror.Maskf(invalidConfigError, "config.Flag.Service.%s must be a comma separated list of state=status code, got %q", name, value)
+		}
+
+		state := check.State(strings.TrimSpace(parts[0]))
+		if _, ok := codes[state]; !ok {
+			return nil, microerror.Maskf(invalidConfigError, "config.Flag.Service.%s must only map the states %s, %s, %s and %s, got %q", name, check.StateHealthy, check.StateInitializing, check.StateDegraded, check.StateUnhealthy, state)
+		}
+
+		code, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || http.StatusText(code) == "" {
+			return nil, microerror.Maskf(invalidConfigError, "config.Flag.Service.%s must map %s to a known HTTP status code, got %q", name, state, strings.TrimSpace(parts[1]))
+		}
+
+		codes[state] = code
+	}
+
+	return codes, nil
+}
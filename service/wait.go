@@ -0,0 +1,142 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/giantswarm/microerror"
+)
+
+const (
+	defaultFlannelWaitInterval    = 1 * time.Second
+	defaultFlannelWaitMaxInterval = 10 * time.Second
+	defaultFlannelWaitTimeout     = 100 * time.Second
+)
+
+const (
+	flannelFileEmpty      = "empty"
+	flannelFileInvalid    = "invalid"
+	flannelFileMissing    = "missing"
+	flannelFileUnreadable = "unreadable"
+)
+
+// waitConfig configures waiting for the flannel file.
+type waitConfig struct {
+	// interval is the initial interval between two attempts to load the
+	// flannel file. It doubles after every attempt up to maxInterval.
+	interval    time.Duration
+	maxInterval time.Duration
+	// timeout is the maximum time to wait for the flannel file. Zero means
+	// waiting until the context is done.
+	timeout time.Duration
+}
+
+// newWaitConfig creates the configuration of waiting for the flannel file
+// from the flags.
+func (c *Config) newWaitConfig() (waitConfig, error) {
+	var err error
+
+	w := waitConfig{}
+
+	w.interval, err = parseDuration("FlannelWaitInterval", c.Flag.Service.FlannelWaitInterval, defaultFlannelWaitInterval)
+	if err != nil {
+		return waitConfig{}, microerror.Mask(err)
+	}
+	w.maxInterval, err = parseDuration("FlannelWaitMaxInterval", c.Flag.Service.FlannelWaitMaxInterval, defaultFlannelWaitMaxInterval)
+	if err != nil {
+		return waitConfig{}, microerror.Mask(err)
+	}
+	w.timeout, err = parseDuration("FlannelWaitTimeout", c.Flag.Service.FlannelWaitTimeout, defaultFlannelWaitTimeout)
+	if err != nil {
+		return waitConfig{}, microerror.Mask(err)
+	}
+
+	if w.interval <= 0 {
+		return waitConfig{}, microerror.Maskf(invalidConfigError, "config.Flag.Service.FlannelWaitInterval must be positive")
+	}
+	if w.maxInterval < w.interval {
+		return waitConfig{}, microerror.Maskf(invalidConfigError, "config.Flag.Service.FlannelWaitMaxInterval must not be smaller than config.Flag.Service.FlannelWaitInterval")
+	}
+	if w.timeout < 0 {
+		return waitConfig{}, microerror.Maskf(invalidConfigError, "config.Flag.Service.FlannelWaitTimeout must not be negative")
+	}
+
+	return w, nil
+}
+
+// waitForFlannelFile waits until the flannel file exists, is readable, is not
+// empty and can be parsed into kvm ips. Attempts are retried with an
+// exponential backoff until the configured timeout is reached or ctx is done.
+// Only changes of the reason for waiting are logged and reported to
+// onWaiting, if given.
+func (c *Config) waitForFlannelFile(ctx context.Context, onWaiting func(reason string)) error {
+	waitCtx := ctx
+	if c.flannelWait.timeout > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, c.flannelWait.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	interval := c.flannelWait.interval
+
+	var lastState string
+	for {
+		state, err := c.tryLoadFlannelFile()
+		if err == nil {
+			if lastState != "" {
+				_ = c.Logger.Log("level", "info", "message", fmt.Sprintf("flannel file %#q loaded after %s", c.Flag.Service.FlannelFile, time.Since(start).Round(time.Second)))
+			}
+			return nil
+		}
+
+		if state != lastState {
+			reason := fmt.Sprintf("waiting for flannel file %#q, file is %s", c.Flag.Service.FlannelFile, state)
+			_ = c.Logger.Log("level", "info", "message", reason, "reason", err.Error())
+			if onWaiting != nil {
+				onWaiting(reason)
+			}
+			lastState = state
+		}
+
+		select {
+		case <-waitCtx.Done():
+			if ctx.Err() != nil {
+				return microerror.Mask(ctx.Err())
+			}
+			return microerror.Maskf(invalidFlannelFileError, "flannel file %#q is still %s after %s: %s", c.Flag.Service.FlannelFile, state, c.flannelWait.timeout, err)
+		case <-time.After(interval):
+		}
+
+		interval *= 2
+		if interval > c.flannelWait.maxInterval {
+			interval = c.flannelWait.maxInterval
+		}
+	}
+}
+
+// tryLoadFlannelFile reads and parses the flannel file once. In case the file
+// cannot be used yet, the returned state tells why.
+func (c *Config) tryLoadFlannelFile() (string, error) {
+	confFile, err := ioutil.ReadFile(c.Flag.Service.FlannelFile)
+	if os.IsNotExist(err) {
+		return flannelFileMissing, microerror.Mask(err)
+	} else if err != nil {
+		return flannelFileUnreadable, microerror.Mask(err)
+	}
+
+	if len(bytes.TrimSpace(confFile)) == 0 {
+		return flannelFileEmpty, microerror.Maskf(invalidFlannelFileError, "%s", c.Flag.Service.FlannelFile)
+	}
+
+	err = c.parseIPs(confFile)
+	if err != nil {
+		return flannelFileInvalid, microerror.Mask(err)
+	}
+
+	return "", nil
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger/microloggertest"
+
+	"github.com/giantswarm/k8s-kvm-health/flag"
+)
+
+func Test_Flannel_WaitForFlannelFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "k8s-kvm-health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	validContent := []byte(`FLANNEL_NETWORK=172.23.3.0/24
+FLANNEL_SUBNET=172.23.3.65/30`)
+
+	tests := []struct {
+		// initialContent is written before waiting starts, nil means the file
+		// does not exist.
+		initialContent []byte
+		// laterContent is written after waiting started, nil means the file is
+		// not touched.
+		laterContent []byte
+		cancel       bool
+		expectedIP   string
+		expectedErr  func(error) bool
+	}{
+		// test 0 - file exists
+		{
+			initialContent: validContent,
+			expectedIP:     "172.23.3.66",
+		},
+		// test 1 - file never created
+		{
+			expectedErr: IsInvalidFlannelFile,
+		},
+		// test 2 - file stays empty
+		{
+			initialContent: []byte("\n"),
+			expectedErr:    IsInvalidFlannelFile,
+		},
+		// test 3 - file stays invalid
+		{
+			initialContent: []byte("FLANNEL_MTU=1450"),
+			expectedErr:    IsInvalidFlannelFile,
+		},
+		// test 4 - file created while waiting
+		{
+			laterContent: validContent,
+			expectedIP:   "172.23.3.66",
+		},
+		// test 5 - empty file filled while waiting
+		{
+			initialContent: []byte(""),
+			laterContent:   validContent,
+			expectedIP:     "172.23.3.66",
+		},
+		// test 6 - cancelled while waiting
+		{
+			cancel:      true,
+			expectedErr: isCanceled,
+		},
+	}
+
+	for index, test := range tests {
+		file := filepath.Join(dir, "subnet.env")
+		_ = os.Remove(file)
+
+		if test.initialContent != nil {
+			err := ioutil.WriteFile(file, test.initialContent, 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		conf := DefaultConfig()
+		conf.Flag = flag.New()
+		conf.Flag.Service.FlannelFile = file
+		conf.Logger = microloggertest.New()
+		conf.flannelWait = waitConfig{
+			interval:    10 * time.Millisecond,
+			maxInterval: 20 * time.Millisecond,
+			timeout:     500 * time.Millisecond,
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+
+		go func(content []byte, cancelWait bool) {
+			time.Sleep(50 * time.Millisecond)
+			if content != nil {
+				_ = ioutil.WriteFile(file, content, 0644)
+			}
+			if cancelWait {
+				cancel()
+			}
+		}(test.laterContent, test.cancel)
+
+		err := conf.waitForFlannelFile(ctx, nil)
+		cancel()
+
+		if test.expectedErr != nil {
+			if !test.expectedErr(err) {
+				t.Fatalf("%d: unexpected error %#v", index, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%d: unexpected error %#v", index, microerror.Mask(err))
+		}
+		if conf.Flag.Service.IPAddress != test.expectedIP {
+			t.Fatalf("%d: Incorrent ip, expected %s but got %s.", index, test.expectedIP, conf.Flag.Service.IPAddress)
+		}
+	}
+}
+
+func isCanceled(err error) bool {
+	return microerror.Cause(err) == context.Canceled
+}
@@ -0,0 +1,150 @@
+// Package watcher watches a single file for content changes. It uses inotify
+// on the directory of the file, so that files replaced by a rename are
+// noticed as well, and falls back to polling when inotify is not available.
+package watcher
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+)
+
+const (
+	defaultPollInterval = 10 * time.Second
+	// settleDelay is the time waited after the last inotify event before the
+	// file is read. Writers often truncate and write a file in multiple steps,
+	// which would otherwise be reported as multiple changes.
+	settleDelay = 100 * time.Millisecond
+)
+
+// Config represents the configuration used to create a watcher.
+type Config struct {
+	// Dependencies.
+	Logger micrologger.Logger
+
+	// Settings.
+	// File is the path of the watched file.
+	File string
+	// PollInterval is the interval the file is checked for changes in case
+	// inotify is not available. Defaults to 10 seconds.
+	PollInterval time.Duration
+}
+
+// Watcher watches a single file for content changes.
+type Watcher struct {
+	// Dependencies.
+	logger micrologger.Logger
+
+	// Settings.
+	file         string
+	pollInterval time.Duration
+}
+
+// New creates a new configured watcher.
+func New(config Config) (*Watcher, error) {
+	// Dependencies.
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.Logger must not be empty")
+	}
+
+	// Settings.
+	if config.File == "" {
+		return nil, microerror.Maskf(invalidConfigError, "config.File must not be empty")
+	}
+	if config.PollInterval < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.PollInterval must not be negative")
+	}
+	if config.PollInterval == 0 {
+		config.PollInterval = defaultPollInterval
+	}
+
+	w := &Watcher{
+		logger: config.Logger,
+
+		file:         filepath.Clean(config.File),
+		pollInterval: config.PollInterval,
+	}
+
+	return w, nil
+}
+
+// Run watches the file until ctx is done. onChange is called whenever the
+// content of the file differs from the content it had when last checked,
+// including the file being removed or created. Run blocks.
+func (w *Watcher) Run(ctx context.Context, onChange func()) {
+	last := w.checksum()
+
+	var events chan fsnotify.Event
+	var errors chan error
+	var tick <-chan time.Time
+
+	settle := time.NewTimer(settleDelay)
+	settle.Stop()
+	defer settle.Stop()
+
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err == nil {
+		err = fsWatcher.Add(filepath.Dir(w.file))
+	}
+	if err == nil {
+		defer fsWatcher.Close()
+		events = fsWatcher.Events
+		errors = fsWatcher.Errors
+	} else {
+		w.logger.Log("level", "warning", "message", fmt.Sprintf("inotify not available for %#q, polling every %s", w.file, w.pollInterval), "stack", fmt.Sprintf("%#v", err)) // nolint
+		ticker := time.NewTicker(w.pollInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-events:
+			if filepath.Clean(e.Name) == w.file {
+				settle.Reset(settleDelay)
+			}
+			continue
+		case <-settle.C:
+		case err := <-errors:
+			// inotify cannot be trusted anymore, e.g. because its event queue
+			// overflowed. We keep going by polling the file.
+			w.logger.Log("level", "warning", "message", fmt.Sprintf("inotify failed for %#q, polling every %s", w.file, w.pollInterval), "stack", fmt.Sprintf("%#v", err)) // nolint
+			events = nil
+			errors = nil
+			if tick == nil {
+				ticker := time.NewTicker(w.pollInterval)
+				defer ticker.Stop()
+				tick = ticker.C
+			}
+		case <-tick:
+		}
+
+		sum := w.checksum()
+		if sum == last {
+			continue
+		}
+		last = sum
+
+		onChange()
+	}
+}
+
+// checksum returns the checksum of the content of the file, or an empty
+// string in case it cannot be read.
+func (w *Watcher) checksum() string {
+	b, err := ioutil.ReadFile(w.file)
+	if err != nil {
+		return ""
+	}
+
+	return fmt.Sprintf("%x", sha256.Sum256(b))
+}
@@ -0,0 +1,79 @@
+package watcher
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+)
+
+func Test_Watcher_Run(t *testing.T) {
+	dir, err := ioutil.TempDir("", "k8s-kvm-health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "subnet.env")
+	err = ioutil.WriteFile(file, []byte("FLANNEL_SUBNET=172.23.3.65/30\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := New(Config{
+		Logger:       microloggertest.New(),
+		File:         file,
+		PollInterval: 50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes := make(chan struct{}, 10)
+	go w.Run(ctx, func() { changes <- struct{}{} })
+
+	// Give the watcher time to set up before changing the file.
+	time.Sleep(100 * time.Millisecond)
+
+	// Unrelated files in the same directory must not be reported.
+	err = ioutil.WriteFile(filepath.Join(dir, "other"), []byte("foo"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// flanneld replaces the file by renaming a temporary file.
+	tmp := filepath.Join(dir, "subnet.env.tmp")
+	err = ioutil.WriteFile(tmp, []byte("FLANNEL_SUBNET=172.23.3.69/30\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Rename(tmp, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected change to be reported")
+	}
+
+	// Writing the same content again is not a change.
+	err = ioutil.WriteFile(file, []byte("FLANNEL_SUBNET=172.23.3.69/30\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-changes:
+		t.Fatal("expected no change to be reported")
+	case <-time.After(300 * time.Millisecond):
+	}
+}